@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 )
 
@@ -31,12 +32,40 @@ func main() {
 		cancel()
 	}()
 
-	configPath := flag.String("config", "./config.yaml", "The path to the configuration file")
+	configPath := flag.String("config", "./config.yaml", "The path to the configuration file or directory")
 	dbName := flag.String("db", "", "Name of the backup database")
 	all := flag.Bool("all", false, "Backup of all databases from the configuration")
 	fileLog := flag.String("file-log", "echodb.log", "Log files from the configuration")
-
-	flag.Parse()
+	asJSON := flag.Bool("json", false, "Print the doctor report or config validation as JSON")
+	tags := flag.String("tag", "", "Backup databases with any of the tags (comma separated)")
+	groups := flag.String("group", "", "Backup databases of the groups (comma separated)")
+	serverKeys := flag.String("server", "", "Backup databases of the servers (comma separated)")
+	exclude := flag.String("exclude", "", "Databases to skip (comma separated)")
+	maxServers := flag.Int("max-parallel-servers", 0, "Maximum number of servers processed at once (overrides settings)")
+	maxDumps := flag.Int("max-parallel-dumps", 0, "Maximum number of dumps at once per server (overrides settings)")
+	maxTransfers := flag.Int("max-parallel-transfers", 0, "Maximum number of dumps at once on all servers (overrides settings)")
+	reportJSON := flag.String("report-json", "", "Write the run summary as JSON to the file")
+	reportJUnit := flag.String("report-junit", "", "Write the run summary as JUnit XML to the file")
+	metricsListen := flag.String("metrics-listen", "", "Serve Prometheus metrics on the address, daemon command only, e.g. :9187")
+	metricsTextfile := flag.String("metrics-textfile", "", "Write Prometheus metrics for the node_exporter textfile collector after each run")
+	restoreFile := flag.String("file", "", "Dump file to restore with the restore command")
+	restoreTarget := flag.String("target", "", "Database to restore into instead of the configured name")
+	restoreGlobals := flag.String("globals", "", "PostgreSQL globals dump applied before the restore")
+	dryRun := flag.Bool("dry-run", false, "Print what would be done without connecting to servers")
+
+	// The first non-flag argument selects a subcommand, e.g. `echodb doctor --all`.
+	// `config` takes an action as the second one: `echodb config validate`.
+	args := os.Args[1:]
+	subcommand, action := "", ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		subcommand = args[0]
+		args = args[1:]
+	}
+	if subcommand == "config" && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action = args[0]
+		args = args[1:]
+	}
+	_ = flag.CommandLine.Parse(args)
 
 	if showVersion {
 		fmt.Printf("echodb version %s\n", version)
@@ -48,12 +77,38 @@ func main() {
 		DbName:     *dbName,
 		All:        *all,
 		FileLog:    *fileLog,
+		JSON:       *asJSON,
+		DryRun:     *dryRun,
+		Tags:       *tags,
+		Groups:     *groups,
+		ServerKeys: *serverKeys,
+		Exclude:    *exclude,
+
+		MaxParallelServers:   *maxServers,
+		MaxParallelDumps:     *maxDumps,
+		MaxParallelTransfers: *maxTransfers,
+		ReportJSON:           *reportJSON,
+		ReportJUnit:          *reportJUnit,
+		MetricsListen:        *metricsListen,
+		MetricsTextfile:      *metricsTextfile,
+		RestoreFile:          *restoreFile,
+		RestoreTarget:        *restoreTarget,
+		RestoreGlobals:       *restoreGlobals,
+	}
+
+	if subcommand == "config" {
+		os.Exit(runConfig(action, &env))
+	}
+
+	if *metricsListen != "" && subcommand != "daemon" {
+		fmt.Println("--metrics-listen requires the daemon command, use --metrics-textfile for one-shot runs")
+		os.Exit(app.ExitConfig)
 	}
 
 	config, err := conf.Load(*configPath)
 	if err != nil {
-		fmt.Printf("configuration loading error : %v", err)
-		os.Exit(1)
+		fmt.Printf("configuration loading error : %v\n", err)
+		os.Exit(app.ExitConfig)
 	}
 	logger := runLog(&env, *config.Settings.Logging)
 
@@ -67,16 +122,70 @@ func main() {
 
 	a := app.NewApp(ctx, config, &env)
 
+	if subcommand == "doctor" {
+		if err := a.RunDoctor(); err != nil {
+			logging.L(ctx).Error("Doctor checks failed", logging.ErrAttr(err))
+			fmt.Printf("doctor checks failed: %v\n", err)
+			os.Exit(app.ExitCode(err))
+		}
+		os.Exit(app.ExitOK)
+	}
+
+	if subcommand == "restore" {
+		if err := a.RunRestore(); err != nil {
+			logging.L(ctx).Error("Restore failed", logging.ErrAttr(err))
+			fmt.Printf("restore failed: %v\n", err)
+			os.Exit(app.ExitCode(err))
+		}
+		os.Exit(app.ExitOK)
+	}
+
+	if subcommand == "daemon" {
+		reload := make(chan os.Signal, 1)
+		signal.Notify(reload, syscall.SIGHUP)
+
+		if err := a.RunDaemon(reload); err != nil {
+			logging.L(ctx).Error("Daemon failed", logging.ErrAttr(err))
+			fmt.Printf("daemon failed: %v\n", err)
+			os.Exit(app.ExitCode(err))
+		}
+		logging.L(ctx).Info("Daemon stopped")
+		os.Exit(app.ExitOK)
+	}
+
+	if subcommand != "" {
+		fmt.Printf("unknown command: %s\n", subcommand)
+		os.Exit(app.ExitConfig)
+	}
+
 	logging.L(ctx).Info("Starting the application...")
 
 	if err := a.MustRun(); err != nil {
 		logging.L(ctx).Error("Application failed to run", logging.ErrAttr(err))
-		fmt.Printf("application failed to run: %v", err)
-		os.Exit(1)
+		fmt.Printf("application failed to run: %v\n", err)
+		os.Exit(app.ExitCode(err))
 	}
 
 	logging.L(ctx).Info("Finished dump...")
-	os.Exit(0)
+	os.Exit(app.ExitOK)
+}
+
+func runConfig(action string, env *app.Env) int {
+	var err error
+	switch action {
+	case "validate":
+		err = app.ValidateConfig(os.Stdout, env.ConfigFile, env.JSON)
+	case "schema":
+		err = app.PrintConfigSchema(os.Stdout)
+	default:
+		err = fmt.Errorf("%w: unknown config action %q, use validate or schema", app.ErrConfig, action)
+	}
+
+	if err != nil {
+		fmt.Printf("config %s failed: %v\n", action, err)
+		return app.ExitCode(err)
+	}
+	return app.ExitOK
 }
 
 func runLog(env *app.Env, isLogging bool) *logging.Logs {
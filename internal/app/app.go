@@ -4,18 +4,30 @@ import (
 	"context"
 	"echodb/internal/backup"
 	"echodb/internal/command"
+	_ "echodb/internal/command/clickhouse"
+	_ "echodb/internal/command/mariadb"
+	_ "echodb/internal/command/mongodb"
+	_ "echodb/internal/command/mssql"
 	_ "echodb/internal/command/mysql"
 	_ "echodb/internal/command/postgres"
+	_ "echodb/internal/command/redis"
+	_ "echodb/internal/command/sqlite"
 	"echodb/internal/config"
 	"echodb/internal/connect"
 	cmdCfg "echodb/internal/domain/command-config"
+	"echodb/internal/hooks"
+	"echodb/internal/metrics"
+	"echodb/internal/report"
+	"echodb/internal/retry"
 	_select "echodb/internal/select"
 	t "echodb/internal/term"
 	"echodb/pkg/logging"
 	"echodb/pkg/utils"
+	"errors"
 	"fmt"
-	"strings"
-	"sync"
+	"os"
+	"path/filepath"
+	"regexp"
 )
 
 type Env struct {
@@ -23,24 +35,74 @@ type Env struct {
 	DbName     string
 	All        bool
 	FileLog    string
+	JSON       bool
+	DryRun     bool
+	Tags       string
+	Groups     string
+	ServerKeys string
+	Exclude    string
+	// Override settings.max_parallel_servers, max_parallel_dumps and
+	// max_parallel_transfers when set.
+	MaxParallelServers   int
+	MaxParallelDumps     int
+	MaxParallelTransfers int
+	ReportJSON           string
+	ReportJUnit          string
+	MetricsListen        string
+	MetricsTextfile      string
+	// RestoreFile is the dump restored by the restore command, into the
+	// database RestoreTarget when it is set. RestoreGlobals is a globals
+	// dump of the server applied first.
+	RestoreFile    string
+	RestoreTarget  string
+	RestoreGlobals string
 }
 
 type DBInfo struct {
 	Server   config.Server
 	Database config.Database
+	// Globals marks the pg_dumpall --globals-only backup of the server,
+	// made with the credentials of Database.
+	Globals bool
+}
+
+// Name returns the name of the backup in file names and reports.
+func (d DBInfo) Name() string {
+	if d.Globals {
+		return config.GlobalsName
+	}
+	return d.Database.GetDisplayName()
+}
+
+// withGlobals prepends the globals backup of the server to the databases of
+// one server when a selected psql database asks for it. It is made once, with
+// the credentials of the first such database.
+func (a *App) withGlobals(dbInfos []DBInfo) []DBInfo {
+	for _, dbInfo := range dbInfos {
+		settings := a.cfg.Settings.ForDatabase(dbInfo.Database)
+		if settings.Driver == "psql" && dbInfo.Database.Postgres.Globals {
+			globals := DBInfo{Server: dbInfo.Server, Database: dbInfo.Database, Globals: true}
+			return append([]DBInfo{globals}, dbInfos...)
+		}
+	}
+	return dbInfos
 }
 
 type App struct {
-	ctx context.Context
-	cfg *config.Config
-	env *Env
+	ctx     context.Context
+	cfg     *config.Config
+	env     *Env
+	metrics *metrics.Registry
+	// limits are shared by all runs when set, as in the daemon.
+	limits *limits
 }
 
 func NewApp(ctx context.Context, cfg *config.Config, env *Env) *App {
 	return &App{
-		ctx: ctx,
-		cfg: cfg,
-		env: env,
+		ctx:     ctx,
+		cfg:     cfg,
+		env:     env,
+		metrics: metrics.New(),
 	}
 }
 
@@ -53,13 +115,13 @@ func (a *App) MustRun() error {
 }
 
 func (a *App) Run() error {
-	if a.env.All == false && a.env.DbName != "" {
-		logging.L(a.ctx).Info("Running the app with the parameters specified (db list)")
-		return a.RunDumpDB()
+	if a.env.DryRun {
+		logging.L(a.ctx).Info("Running the app in dry-run mode")
+		return a.RunDryRun()
 	}
 
-	if a.env.All == true && a.env.DbName == "" {
-		logging.L(a.ctx).Info("Running the app with the parameters specified (db all)")
+	if a.env.hasSelection() {
+		logging.L(a.ctx).Info("Running the app with the parameters specified (db selection)")
 		return a.RunDumpDB()
 	}
 
@@ -75,7 +137,7 @@ func (a *App) RunDumpManual() error {
 	m.SetList(serverKeys)
 	m.SetTitle("Select server")
 
-	if err := runWithCtx(a.ctx, func() error { m.Run(); return nil }); err != nil {
+	if err := runSelect(a.ctx, m); err != nil {
 		return err
 	}
 
@@ -92,7 +154,7 @@ func (a *App) RunDumpManual() error {
 	m.SetList(dbKeys)
 	m.SetTitle("Select database")
 
-	if err := runWithCtx(a.ctx, func() error { m.Run(); return nil }); err != nil {
+	if err := runSelect(a.ctx, m); err != nil {
 		return err
 	}
 
@@ -102,124 +164,101 @@ func (a *App) RunDumpManual() error {
 
 	logging.L(a.ctx).Info("Selected database", logging.StringAttr("database", dbKey))
 
-	if err := a.runBackup(server, db); err != nil {
-		return err
-	}
+	rep := a.runServers(map[string][]DBInfo{
+		serverKey: {{Server: server, Database: db}},
+	})
 
-	return nil
+	return a.finishRun(rep)
 }
 
 func (a *App) RunDumpDB() error {
 	logging.L(a.ctx).Info("Prepare data for creating dumps")
 
-	dbList := strings.Split(a.env.DbName, ",")
-	countDBs := len(dbList)
-
-	serversDatabases := make(map[string][]DBInfo)
+	serversDatabases, err := a.selectDatabases()
+	if err != nil {
+		return err
+	}
 
-	for _, dbName := range dbList {
-		database, ok := a.cfg.Databases[dbName]
-		if !ok {
-			fmt.Printf("Database %s not found\n", dbName)
-			logging.L(a.ctx).Warn("Database not found", logging.StringAttr("name", dbName))
-			countDBs--
-			continue
-		}
+	if err := a.finishRun(a.runServers(serversDatabases)); err != nil {
+		return err
+	}
 
-		server, ok := a.cfg.Servers[database.Server]
-		if !ok {
-			fmt.Printf("Server %s not found\n", database.Server)
-			logging.L(a.ctx).Warn("Server not found", logging.StringAttr("name", database.Server))
-			countDBs--
-			continue
-		}
+	logging.L(a.ctx).Info("All backups completed successfully")
 
-		serversDatabases[database.Server] = append(serversDatabases[database.Server], DBInfo{
-			Server:   server,
-			Database: database,
-		})
+	return nil
+}
 
-		if countDBs == 0 {
-			logging.L(a.ctx).Error("Database and server keys do not match, check the configuration file")
-			return fmt.Errorf("database and server keys do not match, check the configuration file")
-		}
+// finishRun reports the outcome of a run and classifies its error.
+func (a *App) finishRun(rep *report.Report) error {
+	if err := a.writeReport(rep); err != nil {
+		logging.L(a.ctx).Error("Failed to write report", logging.ErrAttr(err))
 	}
 
-	wg := &sync.WaitGroup{}
-	errCh := make(chan error, len(dbList))
-
-	for _, dbInfoList := range serversDatabases {
-		dbListCopy := dbInfoList
-		wg.Add(1)
-		go func(dbInfos []DBInfo) {
-			defer wg.Done()
-			for _, dbInfo := range dbInfos {
-				select {
-				case <-a.ctx.Done():
-					logging.L(a.ctx).Info("Backup cancelled by context")
-					errCh <- fmt.Errorf("backup cancelled for database %s", dbInfo.Database.Name)
-					return
-				default:
-					err := a.runBackup(dbInfo.Server, dbInfo.Database)
-					if err != nil {
-						logging.L(a.ctx).Warn(
-							"Skip creating database",
-							logging.StringAttr("name", dbInfo.Database.Name),
-							logging.ErrAttr(err),
-						)
-						errCh <- err
-						return
-					}
-				}
-			}
-		}(dbListCopy)
+	if a.env.MetricsTextfile != "" {
+		if err := a.metrics.WriteTextfile(a.env.MetricsTextfile); err != nil {
+			logging.L(a.ctx).Error("Failed to write metrics", logging.ErrAttr(err))
+		}
 	}
 
-	wg.Wait()
-	close(errCh)
+	return a.runError(rep)
+}
 
-	for err := range errCh {
-		if err != nil {
-			return err
-		}
+// runError classifies the outcome of a finished run.
+func (a *App) runError(rep *report.Report) error {
+	err := rep.Err()
+	if err == nil {
+		return nil
 	}
 
-	logging.L(a.ctx).Info("All backups completed successfully")
+	if a.ctx.Err() != nil {
+		return fmt.Errorf("%w: %w", ErrCancelled, err)
+	}
 
-	return nil
+	return &RunError{Failed: rep.Failed(), Total: len(rep.Results()), Err: err}
 }
 
-func (a *App) runBackup(server config.Server, db config.Database) error {
-	dataFormat := utils.TemplateData{
-		Server:   server.GetDisplayName(),
-		Database: db.GetDisplayName(),
-		Template: a.cfg.Settings.Template,
+// writeReport prints the summary table and writes the JSON and JUnit
+// reports requested on the command line.
+func (a *App) writeReport(rep *report.Report) error {
+	fmt.Println()
+	if err := rep.PrintTable(os.Stdout); err != nil {
+		return err
 	}
-	nameFile := utils.GetTemplateFileName(dataFormat)
-	logging.L(a.ctx).Info("Generated template", logging.StringAttr("name", nameFile))
 
-	cmdData := &cmdCfg.ConfigData{
+	var errs []error
+	if a.env.ReportJSON != "" {
+		errs = append(errs, rep.WriteJSON(a.env.ReportJSON))
+	}
+	if a.env.ReportJUnit != "" {
+		errs = append(errs, rep.WriteJUnit(a.env.ReportJUnit))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (a *App) commandData(server config.Server, db config.Database, nameFile string) *cmdCfg.ConfigData {
+	settings := a.cfg.Settings.ForDatabase(db)
+	return &cmdCfg.ConfigData{
 		User:       db.User,
 		Password:   db.Password,
 		Name:       db.GetDisplayName(),
-		Port:       db.GetPort(a.cfg.Settings.DBPort),
+		Port:       db.GetPort(settings.DBPort),
 		Key:        server.SSHKey,
 		Host:       server.Host,
 		DumpName:   nameFile,
-		DumpFormat: a.cfg.Settings.DumpFormat,
-	}
-
-	logging.L(a.ctx).Info("Prepare command for dump")
-
-	cmdApp := command.NewApp(&a.cfg.Settings, cmdData)
-	cmdStr, remotePath, err := cmdApp.GetCommand()
-	if err != nil {
-		logging.L(a.ctx).Error("failed to generate command")
-		return fmt.Errorf("failed to generate command: %w", err)
+		DumpFormat: settings.DumpFormat,
+		Options:    db.DumpOptions,
+		MongoDB:    db.MongoDB,
+		Path:       db.Path,
+		Jobs:       db.Jobs,
+		Postgres:   db.Postgres,
+		MSSQL:      db.MSSQL,
+		ClickHouse: db.ClickHouse,
 	}
+}
 
-	logging.L(a.ctx).Info("Prepare connection")
-	conn := connect.New(
+func (a *App) newConnection(server config.Server) *connect.Connect {
+	return connect.New(
 		server.Host,
 		server.User,
 		server.GetPort(a.cfg.Settings.SrvPost),
@@ -229,52 +268,197 @@ func (a *App) runBackup(server config.Server, db config.Database) error {
 		server.Password,
 		*a.cfg.Settings.SSH.IsPassphrase,
 	)
+}
 
-	fmt.Println("Connecting to server...")
-	if err := runWithCtx(a.ctx, conn.Connect); err != nil {
-		logging.L(a.ctx).Error("Failed to connect to server")
-		return err
+// prepareCommand renders the dump file name and builds the dump command
+// for the database.
+func (a *App) prepareCommand(dbInfo DBInfo) (command.Command, error) {
+	server, db := dbInfo.Server, dbInfo.Database
+	settings := a.cfg.Settings.ForDatabase(db)
+	dataFormat := utils.TemplateData{
+		Server:   server.GetDisplayName(),
+		Database: dbInfo.Name(),
+		Template: settings.Template,
 	}
+	nameFile := utils.GetTemplateFileName(dataFormat)
+	logging.L(a.ctx).Info("Generated template", logging.StringAttr("name", nameFile))
+
+	cmdData := a.commandData(server, db, nameFile)
+	cmdData.Globals = dbInfo.Globals
+
+	logging.L(a.ctx).Info("Prepare command for dump")
+
+	cmdApp := command.NewApp(&settings, cmdData)
+	cmd, err := cmdApp.GetCommand()
+	if err != nil {
+		logging.L(a.ctx).Error("failed to generate command")
+		return command.Command{}, fmt.Errorf("failed to generate command: %w", err)
+	}
+
+	return cmd, nil
+}
+
+func (a *App) runBackup(dbInfo DBInfo, progress bool) (res backup.Result, err error) {
+	server, db := dbInfo.Server, dbInfo.Database
+	cmd, err := a.prepareCommand(dbInfo)
+	if err != nil {
+		return backup.Result{}, err
+	}
+	remotePath := cmd.RemotePath
+
+	retrier := retry.New(a.ctx, a.retryPolicy())
+	defer func() {
+		res.Retries = retrier.Retries()
+	}()
 
+	logging.L(a.ctx).Info("Prepare connection")
+	conn := a.newConnection(server)
 	defer func(conn *connect.Connect) {
 		_ = conn.Close()
 	}(conn)
 
-	logging.L(a.ctx).Info("Trying to establish connection to server %s...\n", server.Host)
-	if err := runWithCtx(a.ctx, conn.TestConnection); err != nil {
-		logging.L(a.ctx).Error(
-			"Failed to test connection to server",
-			logging.StringAttr("server", server.Host),
-			logging.ErrAttr(err),
-		)
-		return err
+	dbHooks := a.backupHooks(dbInfo)
+	hookRunner := hooks.New(a.ctx, conn)
+	hookVars := hooks.Vars{
+		Server:    server.GetDisplayName(),
+		Database:  dbInfo.Name(),
+		DumpPath:  remotePath,
+		LocalPath: filepath.Join(a.cfg.Settings.DirDump, filepath.Base(remotePath)),
+	}
+
+	defer func() {
+		if err == nil || errors.Is(err, ErrCancelled) || len(dbHooks.OnError) == 0 {
+			return
+		}
+		hookVars.Status = "failed"
+		if hookErr := hookRunner.Run(hooks.StageOnError, dbHooks.OnError, hookVars); hookErr != nil {
+			logging.L(a.ctx).Error("Failed to run on_error hooks", logging.ErrAttr(hookErr))
+		}
+	}()
+
+	// local-direct dumps connect to the database from here and need the
+	// server only for remote hooks.
+	location := a.cfg.Settings.ForDatabase(db).DumpLocation
+	if location != "local-direct" || hooks.NeedsConnection(dbHooks) {
+		if err := a.connect(conn, server, retrier); err != nil {
+			return backup.Result{}, err
+		}
+		logging.L(a.ctx).Info("The connection has established")
+	}
+
+	hookVars.Status = "running"
+	if err := runWithCtx(a.ctx, func() error {
+		return hookRunner.Run(hooks.StagePre, dbHooks.Pre, hookVars)
+	}); err != nil {
+		return backup.Result{}, err
 	}
-	logging.L(a.ctx).Info("The connection has established")
 
 	logging.L(a.ctx).Info("Preparing for backup creation")
-	backupApp := backup.NewApp(a.ctx, conn, cmdStr, remotePath, a.cfg.Settings.DirDump, a.cfg.Settings.DumpLocation)
+	backupApp := backup.NewApp(
+		a.ctx,
+		conn,
+		cmd,
+		a.cfg.Settings.DirDump,
+		location,
+		retrier,
+		progress,
+	)
 
 	if err := runWithCtx(a.ctx, backupApp.Backup); err != nil {
 		logging.L(a.ctx).Error("Failed to create backup")
-		return err
+		return backup.Result{}, err
 	}
 	logging.L(a.ctx).Info("The backup was successfully created and downloaded")
 
 	if a.cfg.Settings.DirArchived != "" {
 		logging.L(a.ctx).Info("Search for old backups")
-		dbNamePrefix := fmt.Sprintf("%s_%s", server.GetDisplayName(), db.GetDisplayName())
+		pattern := a.archivePattern(dbInfo)
 
 		if err := runWithCtx(a.ctx, func() error {
-			return utils.ArchivedLocalFile(dbNamePrefix, remotePath, a.cfg.Settings.DirDump, a.cfg.Settings.DirArchived)
+			return utils.ArchivedLocalFile(pattern, remotePath, a.cfg.Settings.DirDump, a.cfg.Settings.DirArchived)
 		}); err != nil {
 			logging.L(a.ctx).Error("Failed to archive backups")
-			return err
+			return backup.Result{}, err
 		}
 
 		logging.L(a.ctx).Info("Archived old backups", logging.StringAttr("path", a.cfg.Settings.DirArchived))
 	}
 
-	return nil
+	hookVars.Status = "success"
+	if err := runWithCtx(a.ctx, func() error {
+		return hookRunner.Run(hooks.StagePost, dbHooks.Post, hookVars)
+	}); err != nil {
+		return backupApp.Result(), err
+	}
+
+	return backupApp.Result(), nil
+}
+
+// archivePattern matches the older dumps of the database moved into
+// dir_archived. Dumps of other databases never match, even when they are
+// written at the same time.
+func (a *App) archivePattern(dbInfo DBInfo) *regexp.Regexp {
+	return utils.TemplatePattern(utils.TemplateData{
+		Server:   dbInfo.Server.GetDisplayName(),
+		Database: dbInfo.Name(),
+		Template: a.cfg.Settings.ForDatabase(dbInfo.Database).Template,
+	})
+}
+
+// backupHooks returns the hooks of a backup. Globals backups run the
+// settings hooks only, the hooks of the database belong to its own dump.
+func (a *App) backupHooks(dbInfo DBInfo) config.Hooks {
+	if dbInfo.Globals {
+		return hooks.Merge(a.cfg.Settings.Hooks, config.Hooks{})
+	}
+	return hooks.Merge(a.cfg.Settings.Hooks, dbInfo.Database.Hooks)
+}
+
+// connect opens the SSH connection to the server, retrying transient
+// failures.
+func (a *App) connect(conn *connect.Connect, server config.Server, retrier *retry.Retrier) error {
+	return retrier.Do(retry.StageConnect, func() error {
+		fmt.Println("Connecting to server...")
+		if err := runWithCtx(a.ctx, conn.Connect); err != nil {
+			logging.L(a.ctx).Error("Failed to connect to server")
+			if errors.Is(err, connect.ErrAuth) {
+				return retry.Permanent(err)
+			}
+			return err
+		}
+
+		logging.L(a.ctx).Info("Trying to establish connection to server", logging.StringAttr("server", server.Host))
+		if err := runWithCtx(a.ctx, conn.TestConnection); err != nil {
+			logging.L(a.ctx).Error(
+				"Failed to test connection to server",
+				logging.StringAttr("server", server.Host),
+				logging.ErrAttr(err),
+			)
+			_ = conn.Close()
+			return err
+		}
+		return nil
+	})
+}
+
+func (a *App) retryPolicy() retry.Policy {
+	cfg := a.cfg.Settings.Retry
+	return retry.Policy{
+		MaxAttempts: cfg.MaxAttempts,
+		Backoff:     cfg.Backoff,
+		MaxBackoff:  cfg.MaxBackoff,
+		Stages:      cfg.Stages,
+	}
+}
+
+// runSelect shows the prompt and turns an interrupted selection into
+// ErrCancelled.
+func runSelect(ctx context.Context, m *t.Data) error {
+	err := runWithCtx(ctx, m.Run)
+	if errors.Is(err, t.ErrInterrupted) {
+		return fmt.Errorf("%w: %w", ErrCancelled, err)
+	}
+	return err
 }
 
 func runWithCtx(ctx context.Context, fn func() error) error {
@@ -285,7 +469,7 @@ func runWithCtx(ctx context.Context, fn func() error) error {
 
 	select {
 	case <-ctx.Done():
-		return fmt.Errorf("operation cancelled")
+		return ErrCancelled
 	case err := <-done:
 		return err
 	}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"echodb/internal/config"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ValidateConfig checks the configuration at path without resolving secrets
+// and prints every problem found, as text or JSON. It returns an ErrConfig
+// error when the configuration is invalid.
+func ValidateConfig(w io.Writer, path string, asJSON bool) error {
+	issues, err := config.Validate(path)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrConfig, err)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(append([]config.Issue{}, issues...)); err != nil {
+			return err
+		}
+	} else {
+		for _, issue := range issues {
+			_, _ = fmt.Fprintln(w, issue)
+		}
+		if len(issues) == 0 {
+			_, _ = fmt.Fprintf(w, "%s: configuration is valid\n", path)
+		}
+	}
+
+	if len(issues) > 0 {
+		return fmt.Errorf("%w: %d problem(s) found", ErrConfig, len(issues))
+	}
+	return nil
+}
+
+// PrintConfigSchema prints the JSON Schema of the configuration file.
+func PrintConfigSchema(w io.Writer) error {
+	schema, err := config.Schema()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(schema))
+	return err
+}
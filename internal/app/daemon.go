@@ -0,0 +1,236 @@
+package app
+
+import (
+	"echodb/internal/config"
+	"echodb/internal/schedule"
+	"echodb/pkg/logging"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+type scheduledDB struct {
+	key      string
+	schedule *schedule.Schedule
+}
+
+type daemon struct {
+	app     *App
+	mu      sync.Mutex
+	running map[string]bool
+	wg      sync.WaitGroup
+}
+
+// RunDaemon runs backups in-process according to the `schedule` of every
+// selected database and group until the context is cancelled. A value on
+// reload re-reads the configuration file; runs already in progress keep the
+// old configuration.
+func (a *App) RunDaemon(reload <-chan os.Signal) error {
+	a.limits = a.newLimits()
+	d := &daemon{app: a, running: make(map[string]bool)}
+
+	jobs, err := a.scheduledDatabases()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+	if len(jobs) == 0 {
+		return fmt.Errorf("%w: no selected database or group has a schedule, check the configuration file", ErrConfig)
+	}
+	d.logSchedules(jobs)
+
+	if a.env.MetricsListen != "" {
+		srv := &http.Server{Addr: a.env.MetricsListen, Handler: a.metricsMux()}
+		go func() {
+			logging.L(a.ctx).Info("Serving metrics", logging.StringAttr("address", a.env.MetricsListen))
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logging.L(a.ctx).Error("Metrics server failed", logging.ErrAttr(err))
+			}
+		}()
+		defer func(srv *http.Server) {
+			_ = srv.Close()
+		}(srv)
+	}
+
+	fmt.Println("Daemon started")
+	logging.L(a.ctx).Info("Daemon started", logging.IntAttr("databases", len(jobs)))
+
+	for {
+		now := time.Now()
+		timer := time.NewTimer(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+
+		select {
+		case <-a.ctx.Done():
+			timer.Stop()
+			logging.L(a.ctx).Info("Daemon stopping, waiting for running backups")
+			fmt.Println("Daemon stopping, waiting for running backups...")
+			d.wg.Wait()
+			return nil
+
+		case <-reload:
+			timer.Stop()
+			cfg, err := config.Load(a.env.ConfigFile)
+			if err != nil {
+				logging.L(a.ctx).Error("Failed to reload configuration, keeping the current one", logging.ErrAttr(err))
+				continue
+			}
+
+			app := NewApp(a.ctx, cfg, a.env)
+			app.metrics = a.metrics
+			app.limits = app.newLimits()
+			reloaded, err := app.scheduledDatabases()
+			if err != nil {
+				logging.L(a.ctx).Error("Failed to reload configuration, keeping the current one", logging.ErrAttr(err))
+				continue
+			}
+
+			d.app = app
+			jobs = reloaded
+			logging.L(a.ctx).Info("Configuration reloaded", logging.IntAttr("databases", len(jobs)))
+			d.logSchedules(jobs)
+
+		case tick := <-timer.C:
+			d.runDue(jobs, tick.Truncate(time.Minute))
+		}
+	}
+}
+
+// runDue starts a run for the databases scheduled at tick. Databases whose
+// previous run is still in progress are skipped.
+func (d *daemon) runDue(jobs []scheduledDB, tick time.Time) {
+	a := d.app
+	serversDatabases := make(map[string][]DBInfo)
+	var keys []string
+	batch := make(map[string]bool)
+
+	d.mu.Lock()
+	for _, job := range jobs {
+		if !job.schedule.Match(tick) || batch[job.key] {
+			continue
+		}
+		if d.running[job.key] {
+			logging.L(a.ctx).Warn("Previous backup still running, skipping", logging.StringAttr("name", job.key))
+			continue
+		}
+
+		database := a.cfg.Databases[job.key]
+		server, ok := a.cfg.Servers[database.Server]
+		if !ok {
+			logging.L(a.ctx).Warn("Server not found", logging.StringAttr("name", database.Server))
+			continue
+		}
+
+		d.running[job.key] = true
+		batch[job.key] = true
+		keys = append(keys, job.key)
+		serversDatabases[database.Server] = append(serversDatabases[database.Server], DBInfo{
+			Server:   server,
+			Database: database,
+		})
+	}
+	d.mu.Unlock()
+
+	if len(keys) == 0 {
+		return
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer func() {
+			d.mu.Lock()
+			for _, key := range keys {
+				delete(d.running, key)
+			}
+			d.mu.Unlock()
+		}()
+
+		logging.L(a.ctx).Info("Starting scheduled backups", logging.AnyAttr("databases", keys))
+		if err := a.finishRun(a.runServers(serversDatabases)); err != nil {
+			logging.L(a.ctx).Error("Scheduled backups failed", logging.ErrAttr(err))
+			return
+		}
+		logging.L(a.ctx).Info("Scheduled backups completed", logging.AnyAttr("databases", keys))
+	}()
+}
+
+func (a *App) metricsMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", a.metrics.Handler())
+	return mux
+}
+
+func (d *daemon) logSchedules(jobs []scheduledDB) {
+	now := time.Now()
+	for _, job := range jobs {
+		logging.L(d.app.ctx).Info(
+			"Scheduled database",
+			logging.StringAttr("name", job.key),
+			logging.StringAttr("schedule", job.schedule.String()),
+			logging.TimeAttr("next", job.schedule.Next(now)),
+		)
+	}
+}
+
+// scheduledDatabases collects the schedules of the selected databases and
+// of the groups containing them. A database may have several schedules.
+func (a *App) scheduledDatabases() ([]scheduledDB, error) {
+	keys := a.allKeys()
+	if a.env.hasSelection() {
+		var err error
+		if keys, err = a.selectedKeys(); err != nil {
+			return nil, err
+		}
+	}
+
+	selected := make(map[string]bool, len(keys))
+	var jobs []scheduledDB
+
+	for _, key := range keys {
+		selected[key] = true
+
+		expr := a.cfg.Databases[key].Schedule
+		if expr == "" {
+			continue
+		}
+
+		s, err := schedule.Parse(expr)
+		if err != nil {
+			return nil, fmt.Errorf("database %s: %w", key, err)
+		}
+		jobs = append(jobs, scheduledDB{key: key, schedule: s})
+	}
+
+	groupNames := make([]string, 0, len(a.cfg.Groups))
+	for name := range a.cfg.Groups {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+
+	for _, name := range groupNames {
+		expr := a.cfg.Groups[name].Schedule
+		if expr == "" {
+			continue
+		}
+
+		s, err := schedule.Parse(expr)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", name, err)
+		}
+
+		groupKeys, err := a.cfg.GroupDatabases(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range groupKeys {
+			if selected[key] {
+				jobs = append(jobs, scheduledDB{key: key, schedule: s})
+			}
+		}
+	}
+
+	return jobs, nil
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"echodb/internal/command"
+	"echodb/internal/doctor"
+	"echodb/internal/hooks"
+	"echodb/pkg/logging"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+)
+
+// RunDoctor checks every selected database once without creating dumps and
+// prints a pass/fail report.
+func (a *App) RunDoctor() error {
+	logging.L(a.ctx).Info("Running doctor checks")
+
+	if !a.env.hasSelection() {
+		a.env.All = true
+	}
+
+	serversDatabases, err := a.selectDatabases()
+	if err != nil {
+		return err
+	}
+
+	serverKeys := make([]string, 0, len(serversDatabases))
+	for key := range serversDatabases {
+		serverKeys = append(serverKeys, key)
+	}
+	sort.Strings(serverKeys)
+
+	var reports []doctor.Report
+	failed := 0
+
+	for _, serverKey := range serverKeys {
+		for _, dbInfo := range serversDatabases[serverKey] {
+			if err := a.ctx.Err(); err != nil {
+				return fmt.Errorf("%w: %w", ErrCancelled, err)
+			}
+
+			settings := a.cfg.Settings.ForDatabase(dbInfo.Database)
+			cmdData := a.commandData(dbInfo.Server, dbInfo.Database, "")
+			versionCmd, pingCmd, err := command.NewApp(&settings, cmdData).GetCheckCommands()
+			if err != nil {
+				return fmt.Errorf("failed to generate command: %w", err)
+			}
+			dumpCmd, err := a.prepareCommand(dbInfo)
+			if err != nil {
+				return err
+			}
+
+			// Like the backup, local-direct dumps run here and need the
+			// server only for remote hooks. Only server dumps are written
+			// on the server.
+			local := settings.DumpLocation == "local-direct"
+			conn := a.newConnection(dbInfo.Server)
+			if local && !hooks.NeedsConnection(a.backupHooks(dbInfo)) {
+				conn = nil
+			}
+			dumpDir := ""
+			if settings.DumpLocation == "server" {
+				dumpDir = path.Dir(dumpCmd.RemotePath)
+			}
+
+			fmt.Printf("Checking %s on %s...\n", dbInfo.Name(), dbInfo.Server.GetDisplayName())
+			report := doctor.New(
+				a.ctx,
+				conn,
+				versionCmd,
+				pingCmd,
+				a.cfg.Settings.DirDump,
+				local,
+				dumpDir,
+			).Run(dbInfo.Name(), dbInfo.Server.GetDisplayName())
+			if err := a.ctx.Err(); err != nil {
+				return fmt.Errorf("%w: %w", ErrCancelled, err)
+			}
+
+			if !report.Passed {
+				failed++
+			}
+			reports = append(reports, report)
+		}
+	}
+
+	if a.env.JSON {
+		err = doctor.PrintJSON(os.Stdout, reports)
+	} else {
+		err = doctor.PrintTable(os.Stdout, reports)
+	}
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return &RunError{Failed: failed, Total: len(reports), Err: fmt.Errorf("doctor checks failed")}
+	}
+
+	logging.L(a.ctx).Info("All doctor checks passed")
+	return nil
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"echodb/internal/config"
+	"echodb/internal/hooks"
+	"echodb/pkg/logging"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+const redacted = "******"
+
+// RunDryRun prints what a backup run would do for every selected database
+// without connecting to any server.
+func (a *App) RunDryRun() error {
+	if !a.env.hasSelection() {
+		a.env.All = true
+	}
+
+	serversDatabases, err := a.selectDatabases()
+	if err != nil {
+		return err
+	}
+
+	serverKeys := make([]string, 0, len(serversDatabases))
+	for key := range serversDatabases {
+		serverKeys = append(serverKeys, key)
+	}
+	sort.Strings(serverKeys)
+
+	for _, serverKey := range serverKeys {
+		dbInfos := serversDatabases[serverKey]
+		server := dbInfos[0].Server
+		address := fmt.Sprintf("%s@%s:%s", server.User, server.Host, server.GetPort(a.cfg.Settings.SrvPost))
+
+		fmt.Printf("Server %s (%s)\n", server.GetDisplayName(), serverKey)
+		fmt.Printf("  connect:  ssh %s\n", address)
+
+		for _, dbInfo := range a.withGlobals(dbInfos) {
+			db := dbInfo.Database
+
+			cmd, err := a.prepareCommand(dbInfo)
+			if err != nil {
+				return err
+			}
+			remotePath := cmd.RemotePath
+
+			dbHooks := a.backupHooks(dbInfo)
+			hookVars := hooks.Vars{
+				Server:    server.GetDisplayName(),
+				Database:  dbInfo.Name(),
+				DumpPath:  remotePath,
+				LocalPath: filepath.Join(a.cfg.Settings.DirDump, filepath.Base(remotePath)),
+				Status:    "running",
+			}
+
+			fmt.Printf("  Database %s\n", dbInfo.Name())
+			printHooks(hooks.StagePre, dbHooks.Pre, hookVars)
+			for _, name := range sortedKeys(cmd.Env) {
+				fmt.Printf("    env:      %s=%s (via stdin)\n", name, redacted)
+			}
+			fmt.Printf("    execute:  %s\n", cmd.Cmd)
+
+			switch location := a.cfg.Settings.ForDatabase(db).DumpLocation; location {
+			case "server":
+				localPath := filepath.Join(a.cfg.Settings.DirDump, filepath.Base(remotePath))
+				fmt.Printf("    download: %s -> %s\n", remotePath, localPath)
+				fmt.Printf("    delete:   %s on server\n", remotePath)
+			default:
+				fmt.Printf("    location: %s\n", location)
+			}
+
+			if a.cfg.Settings.DirArchived != "" {
+				fmt.Printf("    archive:  %s matching %s -> %s\n",
+					a.cfg.Settings.DirDump, a.archivePattern(dbInfo), a.cfg.Settings.DirArchived)
+			}
+
+			hookVars.Status = "success"
+			printHooks(hooks.StagePost, dbHooks.Post, hookVars)
+			hookVars.Status = "failed"
+			printHooks(hooks.StageOnError, dbHooks.OnError, hookVars)
+		}
+	}
+
+	logging.L(a.ctx).Info("Dry run finished")
+	return nil
+}
+
+func printHooks(stage string, list []config.Hook, vars hooks.Vars) {
+	for _, hook := range list {
+		fmt.Printf("    %s hook (%s): %s\n", stage, hook.Run, vars.Expand(hook.Command))
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"echodb/internal/backup"
+	"echodb/internal/connect"
+	"errors"
+	"fmt"
+)
+
+// Process exit codes returned by echodb.
+const (
+	ExitOK        = 0
+	ExitFailure   = 1   // unexpected error
+	ExitConfig    = 2   // invalid configuration or selection
+	ExitPartial   = 3   // some backups failed
+	ExitAllFailed = 4   // every backup failed
+	ExitCancelled = 130 // interrupted by the user or a signal
+)
+
+var (
+	// ErrConfig wraps errors caused by the configuration file or the
+	// command line selection.
+	ErrConfig = errors.New("invalid configuration")
+	// ErrCancelled is returned when the run was interrupted.
+	ErrCancelled = errors.New("operation cancelled")
+)
+
+// RunError reports a run in which some of the databases failed.
+type RunError struct {
+	Failed int
+	Total  int
+	Err    error
+}
+
+func (e *RunError) Error() string {
+	return fmt.Sprintf("%d of %d backups failed: %v", e.Failed, e.Total, e.Err)
+}
+
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode maps an error returned by the app onto a process exit code.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	if errors.Is(err, ErrCancelled) || errors.Is(err, context.Canceled) {
+		return ExitCancelled
+	}
+
+	var runErr *RunError
+	if errors.As(err, &runErr) {
+		if runErr.Failed >= runErr.Total {
+			return ExitAllFailed
+		}
+		return ExitPartial
+	}
+
+	if errors.Is(err, ErrConfig) ||
+		errors.Is(err, backup.ErrUnsupportedLocation) ||
+		errors.Is(err, connect.ErrAuth) {
+		return ExitConfig
+	}
+
+	return ExitFailure
+}
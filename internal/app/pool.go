@@ -0,0 +1,281 @@
+package app
+
+import (
+	"context"
+	"echodb/internal/notify"
+	"echodb/internal/report"
+	"echodb/pkg/logging"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// limits are shared by the workers of all servers in a run, and by the
+// overlapping runs of the daemon.
+type limits struct {
+	// servers holds a token for every server being backed up, nil for no
+	// limit.
+	servers chan struct{}
+	// transfers holds a token for every running backup, nil for no limit.
+	transfers chan struct{}
+}
+
+// newLimits returns the limits of max_parallel_servers and
+// max_parallel_transfers.
+func (a *App) newLimits() *limits {
+	return &limits{
+		servers:   tokens(a.maxParallelServers()),
+		transfers: tokens(a.maxParallelTransfers()),
+	}
+}
+
+func tokens(n int) chan struct{} {
+	if n <= 0 {
+		return nil
+	}
+	return make(chan struct{}, n)
+}
+
+// runServers backs up the databases of every server using a pool of
+// max_parallel_servers server workers. Each server runs up to
+// max_parallel_dumps backups at once and all servers together up to
+// max_parallel_transfers. Every database is attempted even when others
+// fail; the outcome of each one is recorded in the report.
+func (a *App) runServers(serversDatabases map[string][]DBInfo) *report.Report {
+	serverKeys := make([]string, 0, len(serversDatabases))
+	for key := range serversDatabases {
+		serverKeys = append(serverKeys, key)
+	}
+	sort.Strings(serverKeys)
+
+	workers := a.maxParallelServers()
+	if workers <= 0 || workers > len(serverKeys) {
+		workers = len(serverKeys)
+	}
+
+	notifier, err := notify.New(a.cfg.Settings.Notify)
+	if err != nil {
+		fmt.Printf("Notifications disabled: %v\n", err)
+		logging.L(a.ctx).Error("Notifications disabled", logging.ErrAttr(err))
+	}
+
+	if a.env.MetricsTextfile != "" {
+		if err := a.metrics.ReadTextfile(a.env.MetricsTextfile); err != nil {
+			logging.L(a.ctx).Error("Failed to load metrics", logging.ErrAttr(err))
+		}
+	}
+
+	// The daemon shares its limits between runs, which may overlap.
+	lim := a.limits
+	progress := !a.concurrent(serversDatabases, workers)
+	if lim == nil {
+		lim = a.newLimits()
+	} else if a.maxParallelTransfers() != 1 {
+		progress = false
+	}
+
+	rep := report.New()
+	wg := &sync.WaitGroup{}
+	queue := make(chan string)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for serverKey := range queue {
+				if !acquire(a.ctx, lim.servers) {
+					for _, dbInfo := range a.withGlobals(serversDatabases[serverKey]) {
+						a.addCancelled(rep, dbInfo)
+					}
+					continue
+				}
+				a.runServer(serversDatabases[serverKey], rep, notifier, lim, progress)
+				release(lim.servers)
+			}
+		}()
+	}
+
+	sent := 0
+feed:
+	for _, serverKey := range serverKeys {
+		select {
+		case <-a.ctx.Done():
+			break feed
+		case queue <- serverKey:
+			sent++
+		}
+	}
+	close(queue)
+
+	for _, serverKey := range serverKeys[sent:] {
+		for _, dbInfo := range serversDatabases[serverKey] {
+			a.addCancelled(rep, dbInfo)
+		}
+	}
+
+	wg.Wait()
+	rep.Finish()
+
+	if notifier.Enabled() {
+		// Notify even when the run was cancelled; channels apply their own timeouts.
+		if err := notifier.NotifyRun(context.WithoutCancel(a.ctx), rep); err != nil {
+			logging.L(a.ctx).Error("Failed to send notification", logging.ErrAttr(err))
+		}
+	}
+
+	return rep
+}
+
+// runServer backs up the databases of one server with max_parallel_dumps
+// workers. progress is set when at most one backup runs at a time, so the
+// progress line of a download is not overwritten by another one.
+func (a *App) runServer(dbInfos []DBInfo, rep *report.Report, notifier *notify.Notifier, lim *limits, progress bool) {
+	if len(dbInfos) == 0 {
+		return
+	}
+	dbInfos = a.withGlobals(dbInfos)
+
+	workers := dbInfos[0].Server.GetMaxParallelDumps(a.maxParallelDumps())
+	if workers > len(dbInfos) {
+		workers = len(dbInfos)
+	}
+
+	wg := &sync.WaitGroup{}
+	queue := make(chan DBInfo)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dbInfo := range queue {
+				if !acquire(a.ctx, lim.transfers) {
+					a.addCancelled(rep, dbInfo)
+					continue
+				}
+				started := time.Now()
+				res, err := a.runBackup(dbInfo, progress)
+				release(lim.transfers)
+				if err != nil {
+					logging.L(a.ctx).Warn(
+						"Failed to create database backup",
+						logging.StringAttr("name", dbInfo.Name()),
+						logging.ErrAttr(err),
+					)
+				}
+
+				// A backup interrupted by Ctrl-C or SIGTERM is cancelled, not failed.
+				var status report.Status
+				if errors.Is(err, ErrCancelled) {
+					status = report.StatusCancelled
+				}
+				result := rep.Add(report.Result{
+					Status:   status,
+					Database: dbInfo.Name(),
+					Server:   dbInfo.Server.GetDisplayName(),
+					File:     res.LocalPath,
+					Size:     res.Size,
+					Retries:  res.Retries,
+					Duration: time.Since(started),
+
+					DumpDuration:     res.DumpDuration,
+					DownloadDuration: res.DownloadDuration,
+				}, err)
+				a.metrics.Observe(result)
+
+				if notifier.PerDatabase() {
+					if err := notifier.NotifyDatabase(context.WithoutCancel(a.ctx), result); err != nil {
+						logging.L(a.ctx).Error("Failed to send notification", logging.ErrAttr(err))
+					}
+				}
+			}
+		}()
+	}
+
+	sent := 0
+feed:
+	for _, dbInfo := range dbInfos {
+		select {
+		case <-a.ctx.Done():
+			logging.L(a.ctx).Info("Backup cancelled by context")
+			break feed
+		case queue <- dbInfo:
+			sent++
+		}
+	}
+	close(queue)
+
+	for _, dbInfo := range dbInfos[sent:] {
+		a.addCancelled(rep, dbInfo)
+	}
+
+	wg.Wait()
+}
+
+func (a *App) addCancelled(rep *report.Report, dbInfo DBInfo) {
+	rep.Add(report.Result{
+		Database: dbInfo.Name(),
+		Server:   dbInfo.Server.GetDisplayName(),
+		Status:   report.StatusCancelled,
+	}, fmt.Errorf("backup cancelled for database %s", dbInfo.Name()))
+}
+
+// concurrent reports whether more than one backup may run at a time with
+// the given number of server workers.
+func (a *App) concurrent(serversDatabases map[string][]DBInfo, workers int) bool {
+	if a.maxParallelTransfers() == 1 {
+		return false
+	}
+	if workers > 1 {
+		return true
+	}
+	for _, dbInfos := range serversDatabases {
+		dumps := dbInfos[0].Server.GetMaxParallelDumps(a.maxParallelDumps())
+		if dumps > 1 && len(a.withGlobals(dbInfos)) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// acquire waits for a token of the limit and reports false when the run is
+// cancelled first. A nil limit has no tokens to wait for.
+func acquire(ctx context.Context, limit chan struct{}) bool {
+	if limit == nil {
+		return ctx.Err() == nil
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case limit <- struct{}{}:
+		return true
+	}
+}
+
+func release(limit chan struct{}) {
+	if limit != nil {
+		<-limit
+	}
+}
+
+func (a *App) maxParallelServers() int {
+	if a.env.MaxParallelServers > 0 {
+		return a.env.MaxParallelServers
+	}
+	return a.cfg.Settings.MaxParallelServers
+}
+
+func (a *App) maxParallelDumps() int {
+	if a.env.MaxParallelDumps > 0 {
+		return a.env.MaxParallelDumps
+	}
+	return a.cfg.Settings.MaxParallelDumps
+}
+
+func (a *App) maxParallelTransfers() int {
+	if a.env.MaxParallelTransfers > 0 {
+		return a.env.MaxParallelTransfers
+	}
+	return a.cfg.Settings.MaxParallelTransfers
+}
@@ -0,0 +1,151 @@
+package app
+
+import (
+	"echodb/internal/command"
+	"echodb/internal/connect"
+	"echodb/internal/hooks"
+	"echodb/internal/restore"
+	"echodb/internal/retry"
+	"echodb/pkg/logging"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// RunRestore uploads the dump given by --file to the server of the single
+// database selected with --db and restores it there, into the database named
+// by --target when it is set. A PostgreSQL globals dump given by --globals is
+// applied before it.
+func (a *App) RunRestore() error {
+	if a.env.RestoreFile == "" {
+		return fmt.Errorf("%w: --file is required for restore", ErrConfig)
+	}
+
+	keys := splitList(a.env.DbName)
+	if len(keys) != 1 {
+		return fmt.Errorf("%w: restore needs exactly one database in --db", ErrConfig)
+	}
+
+	db, ok := a.cfg.Databases[keys[0]]
+	if !ok {
+		return fmt.Errorf("%w: database %s not found", ErrConfig, keys[0])
+	}
+	server, ok := a.cfg.Servers[db.Server]
+	if !ok {
+		return fmt.Errorf("%w: server %s not found", ErrConfig, db.Server)
+	}
+
+	settings := a.cfg.Settings.ForDatabase(db)
+	cmdData := a.commandData(server, db, "")
+	cmdData.Target = a.env.RestoreTarget
+
+	cmdApp := command.NewApp(&settings, cmdData)
+	cmd, err := cmdApp.GetRestoreCommand(a.env.RestoreFile)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+
+	// Roles and tablespaces the dump refers to are created first.
+	var globalsCmd command.Command
+	if a.env.RestoreGlobals != "" {
+		if settings.Driver != "psql" {
+			return fmt.Errorf("%w: --globals is only supported by the psql driver", ErrConfig)
+		}
+		if !strings.Contains(filepath.Base(a.env.RestoreGlobals), ".globals.sql") {
+			return fmt.Errorf("%w: --globals needs a .globals.sql dump", ErrConfig)
+		}
+		if globalsCmd, err = cmdApp.GetRestoreCommand(a.env.RestoreGlobals); err != nil {
+			return fmt.Errorf("%w: %w", ErrConfig, err)
+		}
+	}
+
+	dbHooks := hooks.Merge(a.cfg.Settings.Hooks, db.Hooks)
+	hookVars := hooks.Vars{
+		Server:    server.GetDisplayName(),
+		Database:  cmdData.RestoreName(),
+		LocalPath: a.env.RestoreFile,
+		Status:    "running",
+	}
+
+	if a.env.DryRun {
+		fmt.Printf("Server %s (%s)\n", server.GetDisplayName(), db.Server)
+		fmt.Printf("  Database %s\n", cmdData.RestoreName())
+		printHooks(hooks.StagePreRestore, dbHooks.PreRestore, hookVars)
+		if a.env.RestoreGlobals != "" {
+			fmt.Printf("    upload:   %s -> stdin\n", a.env.RestoreGlobals)
+			fmt.Printf("    execute:  %s\n", globalsCmd.Cmd)
+		}
+		for _, name := range sortedKeys(cmd.Env) {
+			fmt.Printf("    env:      %s=%s (via stdin)\n", name, redacted)
+		}
+		fmt.Printf("    upload:   %s -> stdin\n", a.env.RestoreFile)
+		fmt.Printf("    execute:  %s\n", cmd.Cmd)
+		hookVars.Status = "success"
+		printHooks(hooks.StagePostRestore, dbHooks.PostRestore, hookVars)
+		return nil
+	}
+
+	logging.L(a.ctx).Info(
+		"Restoring database",
+		logging.StringAttr("database", cmdData.RestoreName()),
+		logging.StringAttr("server", server.Host),
+	)
+
+	conn := a.newConnection(server)
+	defer func(conn *connect.Connect) {
+		_ = conn.Close()
+	}(conn)
+
+	fmt.Println("Connecting to server...")
+	if err := runWithCtx(a.ctx, conn.Connect); err != nil {
+		logging.L(a.ctx).Error("Failed to connect to server", logging.ErrAttr(err))
+		return err
+	}
+
+	// A failed upload is retried over a new connection, as the old one is
+	// usually lost with it.
+	retrier := retry.New(a.ctx, a.retryPolicy())
+	upload := func(cmd command.Command, file string) error {
+		attempt := 0
+		return retrier.Do(retry.StageUpload, func() error {
+			if attempt++; attempt > 1 {
+				_ = conn.Close()
+				if err := runWithCtx(a.ctx, conn.Connect); err != nil {
+					return err
+				}
+			}
+			return restore.NewApp(a.ctx, conn, cmd, file).Restore()
+		})
+	}
+
+	hookRunner := hooks.New(a.ctx, conn)
+	err = runWithCtx(a.ctx, func() error {
+		if err := hookRunner.Run(hooks.StagePreRestore, dbHooks.PreRestore, hookVars); err != nil {
+			return err
+		}
+		if a.env.RestoreGlobals != "" {
+			logging.L(a.ctx).Info("Restoring globals", logging.StringAttr("file", a.env.RestoreGlobals))
+			if err := upload(globalsCmd, a.env.RestoreGlobals); err != nil {
+				return fmt.Errorf("failed to restore globals: %w", err)
+			}
+		}
+		return upload(cmd, a.env.RestoreFile)
+	})
+
+	// post_restore hooks restart what pre_restore stopped, so they run
+	// after a failed restore too.
+	hookVars.Status = "success"
+	if err != nil {
+		hookVars.Status = "failed"
+	}
+	if hookErr := hookRunner.Run(hooks.StagePostRestore, dbHooks.PostRestore, hookVars); hookErr != nil {
+		logging.L(a.ctx).Error("Failed to run post_restore hooks", logging.ErrAttr(hookErr))
+		err = errors.Join(err, hookErr)
+	}
+
+	if err != nil && !errors.Is(err, ErrCancelled) {
+		return &RunError{Failed: 1, Total: 1, Err: err}
+	}
+	return err
+}
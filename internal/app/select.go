@@ -0,0 +1,157 @@
+package app
+
+import (
+	"echodb/pkg/logging"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func (e *Env) hasSelection() bool {
+	return e.All || e.DbName != "" || e.Tags != "" || e.Groups != "" || e.ServerKeys != ""
+}
+
+// selectedKeys resolves the database keys chosen with --db, --all, --tag,
+// --group and --server. Every selector given narrows the result, so
+// `--group nightly --tag prod` selects the prod databases of the nightly
+// group. Keys listed with --exclude are removed last.
+func (a *App) selectedKeys() ([]string, error) {
+	var selected map[string]bool
+
+	narrow := func(keys []string) {
+		next := make(map[string]bool, len(keys))
+		for _, key := range keys {
+			if selected == nil || selected[key] {
+				next[key] = true
+			}
+		}
+		selected = next
+	}
+
+	if a.env.All {
+		narrow(a.allKeys())
+	}
+
+	if a.env.DbName != "" {
+		var keys []string
+		for _, dbName := range splitList(a.env.DbName) {
+			if _, ok := a.cfg.Databases[dbName]; !ok {
+				fmt.Printf("Database %s not found\n", dbName)
+				logging.L(a.ctx).Warn("Database not found", logging.StringAttr("name", dbName))
+				continue
+			}
+			keys = append(keys, dbName)
+		}
+		narrow(keys)
+	}
+
+	if a.env.Groups != "" {
+		var keys []string
+		for _, group := range splitList(a.env.Groups) {
+			groupKeys, err := a.cfg.GroupDatabases(group)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrConfig, err)
+			}
+			keys = append(keys, groupKeys...)
+		}
+		narrow(keys)
+	}
+
+	if a.env.Tags != "" {
+		var keys []string
+		for key, db := range a.cfg.Databases {
+			for _, tag := range splitList(a.env.Tags) {
+				if db.HasTag(tag) {
+					keys = append(keys, key)
+					break
+				}
+			}
+		}
+		narrow(keys)
+	}
+
+	if a.env.ServerKeys != "" {
+		var keys []string
+		for _, serverKey := range splitList(a.env.ServerKeys) {
+			if _, ok := a.cfg.Servers[serverKey]; !ok {
+				return nil, fmt.Errorf("%w: server %s not found", ErrConfig, serverKey)
+			}
+			for key, db := range a.cfg.Databases {
+				if db.Server == serverKey {
+					keys = append(keys, key)
+				}
+			}
+		}
+		narrow(keys)
+	}
+
+	for _, key := range splitList(a.env.Exclude) {
+		delete(selected, key)
+	}
+
+	keys := make([]string, 0, len(selected))
+	for key := range selected {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// selectDatabases resolves the selected databases and groups them by
+// server key.
+func (a *App) selectDatabases() (map[string][]DBInfo, error) {
+	keys, err := a.selectedKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	serversDatabases := make(map[string][]DBInfo)
+
+	for _, key := range keys {
+		database, ok := a.cfg.Databases[key]
+		if !ok {
+			fmt.Printf("Database %s not found\n", key)
+			logging.L(a.ctx).Warn("Database not found", logging.StringAttr("name", key))
+			continue
+		}
+
+		server, ok := a.cfg.Servers[database.Server]
+		if !ok {
+			fmt.Printf("Server %s not found\n", database.Server)
+			logging.L(a.ctx).Warn("Server not found", logging.StringAttr("name", database.Server))
+			continue
+		}
+
+		serversDatabases[database.Server] = append(serversDatabases[database.Server], DBInfo{
+			Server:   server,
+			Database: database,
+		})
+	}
+
+	if len(serversDatabases) == 0 {
+		logging.L(a.ctx).Error("No databases matched the selection, check the configuration file")
+		return nil, fmt.Errorf("%w: no databases matched the selection, check the configuration file", ErrConfig)
+	}
+
+	return serversDatabases, nil
+}
+
+func (a *App) allKeys() []string {
+	keys := make([]string, 0, len(a.cfg.Databases))
+	for key := range a.cfg.Databases {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
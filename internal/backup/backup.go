@@ -1,9 +1,14 @@
 package backup
 
 import (
+	"bytes"
 	"context"
+	"echodb/internal/command"
 	"echodb/internal/connect"
+	"echodb/internal/retry"
 	"echodb/pkg/logging"
+	"echodb/pkg/utils"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -14,30 +19,62 @@ import (
 	"golang.org/x/crypto/ssh"
 )
 
+// ErrUnsupportedLocation is returned for a `location` the backup cannot handle.
+var ErrUnsupportedLocation = errors.New("unsupported backup dump location")
+
 type Backup struct {
 	ctx          context.Context
 	conn         *connect.Connect
 	backupCmd    string
+	backupEnv    map[string]string
 	remotePath   string
 	localDir     string
 	dumpLocation string
+	retrier      *retry.Retrier
+	// progress prints the progress of a download on one line, which only
+	// works while no other backup prints to the terminal.
+	progress bool
+	result   Result
+}
+
+// permanentDumpErrors are fragments of dump tool output that mean retrying
+// the dump cannot succeed.
+var permanentDumpErrors = []string{
+	"does not exist",
+	"Unknown database",
+	"authentication failed",
+	"Access denied",
+	"command not found",
+}
+
+// Result describes the dump downloaded by a finished backup.
+type Result struct {
+	LocalPath        string
+	Size             int64
+	Retries          int
+	DumpDuration     time.Duration
+	DownloadDuration time.Duration
 }
 
 func NewApp(
 	ctx context.Context,
 	conn *connect.Connect,
-	backupCmd,
-	remotePath,
+	cmd command.Command,
 	localDir,
 	dumpLocation string,
+	retrier *retry.Retrier,
+	progress bool,
 ) *Backup {
 	return &Backup{
 		ctx:          ctx,
 		conn:         conn,
-		backupCmd:    backupCmd,
-		remotePath:   remotePath,
+		backupCmd:    cmd.Cmd,
+		backupEnv:    cmd.Env,
+		remotePath:   cmd.RemotePath,
 		localDir:     localDir,
 		dumpLocation: dumpLocation,
+		retrier:      retrier,
+		progress:     progress,
 	}
 }
 
@@ -54,10 +91,14 @@ func (b *Backup) Backup() error {
 			"Unsupported backup dump location",
 			logging.StringAttr("location", b.dumpLocation),
 		)
-		return fmt.Errorf("unsupported backup dump location: %s", b.dumpLocation)
+		return fmt.Errorf("%w: %s", ErrUnsupportedLocation, b.dumpLocation)
 	}
 }
 
+func (b *Backup) Result() Result {
+	return b.result
+}
+
 func (b *Backup) backupByServer() error {
 
 	isRemoveDump := true
@@ -78,12 +119,13 @@ func (b *Backup) backupByServer() error {
 
 		logging.L(b.ctx).Info("Creating dump", logging.StringAttr("name", b.remotePath))
 		fmt.Println("Creating dump: ", b.remotePath)
-		if _, err := b.conn.RunCommand(b.backupCmd); err != nil {
+		if err := b.retrier.Do(retry.StageDump, b.createDump); err != nil {
 			logging.L(b.ctx).Error("Failed to create dump")
-			return fmt.Errorf("failed to create dump: %v", err)
+			return fmt.Errorf("failed to create dump: %w", err)
 		}
 
-		dumpCreateTimeSec := fmt.Sprintf("%.2f sec", time.Since(dumpCreateTimeNow).Seconds())
+		b.result.DumpDuration = time.Since(dumpCreateTimeNow)
+		dumpCreateTimeSec := fmt.Sprintf("%.2f sec", b.result.DumpDuration.Seconds())
 		logging.L(b.ctx).Info(
 			"The dump was successfully created",
 			logging.StringAttr("time", dumpCreateTimeSec),
@@ -92,12 +134,13 @@ func (b *Backup) backupByServer() error {
 
 	logging.L(b.ctx).Info("Downloading dump", logging.StringAttr("name", b.remotePath))
 	dumpDownloadTimeNow := time.Now()
-	if err := b.downloadFile(); err != nil {
+	if err := b.retrier.Do(retry.StageDownload, b.downloadFile); err != nil {
 		logging.L(b.ctx).Error("Failed to download dump")
-		return fmt.Errorf("failed to download dump: %v", err)
+		return fmt.Errorf("failed to download dump: %w", err)
 	}
 
-	dumpDownloadTimeSec := fmt.Sprintf("%.2f sec", time.Since(dumpDownloadTimeNow).Seconds())
+	b.result.DownloadDuration = time.Since(dumpDownloadTimeNow)
+	dumpDownloadTimeSec := fmt.Sprintf("%.2f sec", b.result.DownloadDuration.Seconds())
 
 	logging.L(b.ctx).Info("The dump was successfully downloaded", logging.StringAttr("time", dumpDownloadTimeSec))
 
@@ -115,12 +158,112 @@ func (b *Backup) backupByServer() error {
 	return nil
 }
 
+func (b *Backup) createDump() error {
+	output, err := b.conn.RunCommandEnv(b.backupCmd, b.backupEnv)
+	return dumpError(output, err)
+}
+
+// dumpError adds the output of a failed dump command to err and marks errors
+// that retrying cannot fix as permanent.
+func dumpError(output string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if output = strings.TrimSpace(output); output != "" {
+		err = fmt.Errorf("%w: %s", err, output)
+	}
+	for _, fragment := range permanentDumpErrors {
+		if strings.Contains(output, fragment) {
+			return retry.Permanent(err)
+		}
+	}
+	return err
+}
+
+// backupByLocalSSH runs the dump command on the server and streams its
+// output over the SSH session into the local file, so nothing is written on
+// the server.
 func (b *Backup) backupByLocalSSH() error {
-	panic("not implement")
+	return b.dumpToLocalFile("Streaming dump", b.streamDump)
 }
 
+// backupLocalDirect runs the dump command on this machine, connected
+// directly to the database, and writes its output into the local file.
 func (b *Backup) backupLocalDirect() error {
-	panic("not implement")
+	return b.dumpToLocalFile("Running local dump", b.runLocalDump)
+}
+
+func (b *Backup) dumpToLocalFile(action string, dump func(io.Writer) (string, error)) error {
+	logging.L(b.ctx).Info(action, logging.StringAttr("name", b.remotePath))
+	fmt.Printf("%s: %s\n", action, filepath.Base(b.remotePath))
+
+	dumpTimeNow := time.Now()
+	if err := b.retrier.Do(retry.StageDump, func() error {
+		return b.writeDump(dump)
+	}); err != nil {
+		logging.L(b.ctx).Error("Failed to create dump")
+		return fmt.Errorf("failed to create dump: %w", err)
+	}
+
+	b.result.DumpDuration = time.Since(dumpTimeNow)
+	logging.L(b.ctx).Info(
+		"The dump was successfully created",
+		logging.StringAttr("time", fmt.Sprintf("%.2f sec", b.result.DumpDuration.Seconds())),
+	)
+
+	return nil
+}
+
+// writeDump writes the output of dump into the local file and removes the
+// file when the dump fails.
+func (b *Backup) writeDump(dump func(io.Writer) (string, error)) error {
+	localPath := filepath.Join(b.localDir, filepath.Base(b.remotePath))
+
+	started := time.Now()
+	outFile, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to create local file: %v", err)
+	}
+
+	progress := &progressWriter{w: outFile, print: b.progress}
+	output, err := dump(progress)
+	closeErr := outFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(localPath)
+		return dumpError(output, err)
+	}
+
+	b.printComplete(localPath, progress.done)
+
+	// The dump streams into the file, so the transfer lasts as long as the
+	// successful attempt.
+	b.result.LocalPath = localPath
+	b.result.Size = progress.done
+	b.result.DownloadDuration = time.Since(started)
+	return nil
+}
+
+func (b *Backup) streamDump(w io.Writer) (string, error) {
+	return b.conn.StreamCommandEnv(b.backupCmd, b.backupEnv, nil, w)
+}
+
+func (b *Backup) runLocalDump(w io.Writer) (string, error) {
+	cmd := utils.ShellCommand(b.ctx, b.backupCmd)
+	cmd.Env = os.Environ()
+	for name, value := range b.backupEnv {
+		cmd.Env = append(cmd.Env, name+"="+value)
+	}
+
+	var output bytes.Buffer
+	cmd.Stdout = w
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	return output.String(), err
 }
 
 func (b *Backup) downloadFile() error {
@@ -177,7 +320,9 @@ func (b *Backup) downloadFile() error {
 				return err
 			}
 			downloaded += int64(n)
-			printProgress(downloaded, totalSize)
+			if b.progress {
+				printProgress(downloaded, totalSize)
+			}
 		}
 		if readErr == io.EOF {
 			break
@@ -187,9 +332,41 @@ func (b *Backup) downloadFile() error {
 		}
 	}
 
-	fmt.Println("\nDownload complete:", localPath)
+	if err := session.Wait(); err != nil {
+		return err
+	}
+
+	b.printComplete(localPath, downloaded)
+
+	b.result.LocalPath = localPath
+	b.result.Size = downloaded
+	return nil
+}
 
-	return session.Wait()
+// printComplete ends the progress line, or reports the file on its own
+// line when progress is not printed.
+func (b *Backup) printComplete(localPath string, size int64) {
+	if b.progress {
+		fmt.Println("\nDownload complete:", localPath)
+		return
+	}
+	fmt.Printf("Download complete: %s (%d bytes)\n", localPath, size)
+}
+
+// progressWriter prints the number of bytes written so far.
+type progressWriter struct {
+	w     io.Writer
+	done  int64
+	print bool
+}
+
+func (p *progressWriter) Write(data []byte) (int, error) {
+	n, err := p.w.Write(data)
+	p.done += int64(n)
+	if p.print {
+		printProgress(p.done, 0)
+	}
+	return n, err
 }
 
 func printProgress(done, total int64) {
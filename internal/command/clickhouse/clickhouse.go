@@ -0,0 +1,156 @@
+package clickhouse
+
+import (
+	"echodb/internal/command"
+	"echodb/internal/config"
+	cmdCfg "echodb/internal/domain/command-config"
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultBackupDir = "/var/lib/clickhouse/backups"
+	// toolDir is the default local storage of clickhouse-backup.
+	toolDir = "/var/lib/clickhouse/backup"
+)
+
+type ClickHouseGenerator struct{}
+
+// Generate writes a tar.gz backup of the database, or of the selected tables,
+// into the backup directory. With the auto tool clickhouse-backup is used when
+// it is installed, BACKUP ... TO File() of the server otherwise.
+func (g ClickHouseGenerator) Generate(data *cmdCfg.ConfigData, settings *config.Settings) command.Command {
+	remotePath := fmt.Sprintf("%s/%s.tar.gz", backupDir(data), data.DumpName)
+
+	var cmd string
+	switch data.ClickHouse.Tool {
+	case "native":
+		cmd = g.nativeBackup(data, remotePath)
+	case "clickhouse-backup":
+		cmd = g.toolBackup(data, remotePath)
+	default:
+		cmd = fmt.Sprintf("if command -v clickhouse-backup > /dev/null 2>&1; then %s; else %s; fi",
+			g.toolBackup(data, remotePath), g.nativeBackup(data, remotePath))
+	}
+
+	return command.Command{
+		Cmd:        withConfigFile(cmd),
+		RemotePath: remotePath,
+		Env:        command.PasswordEnv("CLICKHOUSE_PASSWORD", data.Password),
+	}
+}
+
+// Restore writes the uploaded backup into the backup directory and restores
+// it with the tool that created it. clickhouse-backup archives contain the
+// backup directory, native ones a .backup file at the root. The database is
+// renamed to the restore target.
+func (g ClickHouseGenerator) Restore(data *cmdCfg.ConfigData, settings *config.Settings, file string) command.Command {
+	source, target := quoteName(data.Name), quoteName(data.RestoreName())
+
+	// The query is quoted in parts around the file name in $t.
+	native := fmt.Sprintf(`%s --query %s"$t"%s`, g.client(data),
+		command.Quote(fmt.Sprintf("RESTORE DATABASE %s AS %s FROM File('", source, target)), command.Quote("')"))
+	tool := fmt.Sprintf(`n=$(tar -tf "$t" | head -n 1 | cut -d/ -f1) && tar -xf "$t" -C %s && `+
+		`{ %s restore --restore-database-mapping=%s %s "$n"; rc=$?; clickhouse-backup delete local "$n"; [ $rc -eq 0 ]; }`,
+		toolDir, g.tool(data), command.Quote(data.Name+":"+data.RestoreName()), tableFlag(data))
+
+	cmd := fmt.Sprintf(`t=%s/echodb_restore_$$.tar && cat > "$t" && chmod 644 "$t" && `+
+		`if tar -tf "$t" | grep -qx '\(\./\)\{0,1\}\.backup'; then %s; else %s; fi; rc=$?; rm -f "$t"; [ $rc -eq 0 ]`,
+		command.Quote(backupDir(data)), native, tool)
+
+	return command.Command{
+		Cmd: withConfigFile(cmd),
+		Env: command.PasswordEnv("CLICKHOUSE_PASSWORD", data.Password),
+	}
+}
+
+func (g ClickHouseGenerator) Version() string {
+	return "clickhouse-client --version"
+}
+
+func (g ClickHouseGenerator) Ping(data *cmdCfg.ConfigData, settings *config.Settings) command.Command {
+	return command.Command{
+		Cmd: withConfigFile(g.client(data) + " --query 'SELECT 1'"),
+		Env: command.PasswordEnv("CLICKHOUSE_PASSWORD", data.Password),
+	}
+}
+
+// nativeBackup runs BACKUP on the server, which writes the archive itself.
+// The backup directory must be listed in backups.allowed_path.
+func (g ClickHouseGenerator) nativeBackup(data *cmdCfg.ConfigData, remotePath string) string {
+	what := "DATABASE " + quoteName(data.Name)
+	if tables := data.ClickHouse.Tables; len(tables) > 0 {
+		names := make([]string, len(tables))
+		for i, table := range tables {
+			names[i] = "TABLE " + quoteName(data.Name) + "." + quoteName(table)
+		}
+		what = strings.Join(names, ", ")
+	}
+
+	query := fmt.Sprintf("BACKUP %s TO File('%s')", what, strings.ReplaceAll(remotePath, "'", `\'`))
+	return fmt.Sprintf("%s --query %s", g.client(data), command.Quote(query))
+}
+
+// toolBackup creates a local clickhouse-backup backup, packs it into the
+// remote path and deletes it again.
+func (g ClickHouseGenerator) toolBackup(data *cmdCfg.ConfigData, remotePath string) string {
+	name := command.Quote(data.DumpName)
+	return fmt.Sprintf("{ %s create %s %s && tar -C %s -czf %s %s; rc=$?; clickhouse-backup delete local %s; [ $rc -eq 0 ]; }",
+		g.tool(data), tableFlag(data), name, toolDir, remotePath, name, name)
+}
+
+// client returns the clickhouse-client invocation with the password config
+// file in $f.
+func (g ClickHouseGenerator) client(data *cmdCfg.ConfigData) string {
+	return fmt.Sprintf(`clickhouse-client --config-file="$f" --host 127.0.0.1 --port %s --user %s`, port(data), data.User)
+}
+
+// tool returns the clickhouse-backup invocation. The connection is passed in
+// its environment variables.
+func (g ClickHouseGenerator) tool(data *cmdCfg.ConfigData) string {
+	return fmt.Sprintf("CLICKHOUSE_HOST=127.0.0.1 CLICKHOUSE_PORT=%s CLICKHOUSE_USERNAME=%s clickhouse-backup", port(data), data.User)
+}
+
+// tableFlag returns the --tables pattern of clickhouse-backup.
+func tableFlag(data *cmdCfg.ConfigData) string {
+	tables := data.ClickHouse.Tables
+	if len(tables) == 0 {
+		return command.Quote("--tables=" + data.Name + ".*")
+	}
+
+	names := make([]string, len(tables))
+	for i, table := range tables {
+		names[i] = data.Name + "." + table
+	}
+	return command.Quote("--tables=" + strings.Join(names, ","))
+}
+
+func port(data *cmdCfg.ConfigData) string {
+	if data.Port == "" {
+		return "9000"
+	}
+	return data.Port
+}
+
+func backupDir(data *cmdCfg.ConfigData) string {
+	if data.ClickHouse.BackupDir != "" {
+		return strings.TrimSuffix(data.ClickHouse.BackupDir, "/")
+	}
+	return defaultBackupDir
+}
+
+// quoteName quotes a ClickHouse identifier.
+func quoteName(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "\\`") + "`"
+}
+
+// withConfigFile runs cmd with a client config file in $f holding the
+// password from $CLICKHOUSE_PASSWORD. clickhouse-backup reads the variable
+// itself.
+func withConfigFile(cmd string) string {
+	return command.WithSecretFile(`password: '%s'\n`, command.YAMLQuotedEnv("CLICKHOUSE_PASSWORD"), ".yaml", cmd)
+}
+
+func init() {
+	command.Register("clickhouse", ClickHouseGenerator{})
+}
@@ -4,6 +4,7 @@ import (
 	"echodb/internal/config"
 	cmdCfg "echodb/internal/domain/command-config"
 	"fmt"
+	"strings"
 )
 
 type Settings struct {
@@ -18,12 +19,91 @@ func NewApp(appCfg *config.Settings, config *cmdCfg.ConfigData) *Settings {
 	}
 }
 
-func (s *Settings) GetCommand() (string, string, error) {
+func (s *Settings) GetCommand() (Command, error) {
 	gen, ok := GetGenerator(s.AppCfg.Driver)
 	if !ok {
-		return "", "", fmt.Errorf("unsupported driver: %s", s.AppCfg.Driver)
+		return Command{}, fmt.Errorf("unsupported driver: %s", s.AppCfg.Driver)
 	}
 
-	cmd, remotePath := gen.Generate(s.Config, s.AppCfg)
-	return cmd, remotePath, nil
+	return gen.Generate(s.Config, s.AppCfg), nil
+}
+
+func (s *Settings) GetCheckCommands() (string, Command, error) {
+	gen, ok := GetGenerator(s.AppCfg.Driver)
+	if !ok {
+		return "", Command{}, fmt.Errorf("unsupported driver: %s", s.AppCfg.Driver)
+	}
+
+	return gen.Version(), gen.Ping(s.Config, s.AppCfg), nil
+}
+
+// GetRestoreCommand returns the command restoring the dump file. Gzipped
+// files are decompressed on the server.
+func (s *Settings) GetRestoreCommand(file string) (Command, error) {
+	gen, ok := GetGenerator(s.AppCfg.Driver)
+	if !ok {
+		return Command{}, fmt.Errorf("unsupported driver: %s", s.AppCfg.Driver)
+	}
+
+	restorer, ok := gen.(Restorer)
+	if !ok {
+		return Command{}, fmt.Errorf("driver %s does not support restore", s.AppCfg.Driver)
+	}
+
+	cmd := restorer.Restore(s.Config, s.AppCfg, file)
+	if strings.HasSuffix(file, ".gz") {
+		cmd.Cmd = fmt.Sprintf("gzip -dc | { %s; }", cmd.Cmd)
+	}
+	return cmd, nil
+}
+
+// DBHost returns the address the dump tool connects to. The tool runs on
+// the server next to the database, except for the local-direct location.
+func DBHost(settings *config.Settings, data *cmdCfg.ConfigData) string {
+	if settings.DumpLocation == "local-direct" {
+		return data.Host
+	}
+	return "127.0.0.1"
+}
+
+// Quote quotes s for the POSIX shell.
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Pipe pipes the output of cmd through filter, e.g. gzip. Unlike a plain
+// pipe, which exits with the status of filter, the result fails when cmd
+// fails. The status of cmd is passed through a temporary file, as dash has
+// no pipefail. The result is a single group, so it can be redirected.
+func Pipe(cmd, filter string) string {
+	return fmt.Sprintf(`{ p=$(mktemp) && { { %s; echo $? > "$p"; } | %s; rc=$?; r=$(cat "$p"); rm -f "$p"; `+
+		`[ "$r" = 0 ] || rc=${r:-1}; (exit $rc); }; }`, cmd, filter)
+}
+
+// WithSecretFile runs cmd with a temporary file in $f, mode 600, holding
+// the output of printf with format and value, a shell word such as
+// "$MYSQL_PWD". suffix, e.g. .yaml, ends the file name when the tool needs
+// it. The file is removed afterwards, keeping the exit code of cmd.
+func WithSecretFile(format, value, suffix, cmd string) string {
+	mktemp := "mktemp"
+	if suffix != "" {
+		mktemp += " --suffix=" + suffix
+	}
+	return fmt.Sprintf(`f=$(%s) && chmod 600 "$f" && printf %s %s > "$f" && %s; rc=$?; rm -f "$f"; exit $rc`,
+		mktemp, Quote(format), value, cmd)
+}
+
+// YAMLQuotedEnv returns a shell word expanding to the variable name with
+// single quotes doubled, for a single-quoted YAML scalar.
+func YAMLQuotedEnv(name string) string {
+	return fmt.Sprintf(`"$(printf '%%s' "$%s" | sed "s/'/''/g")"`, name)
+}
+
+// PasswordEnv returns env with the password set under name, or nil when
+// there is no password.
+func PasswordEnv(name, password string) map[string]string {
+	if password == "" {
+		return nil
+	}
+	return map[string]string{name: password}
 }
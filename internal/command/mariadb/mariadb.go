@@ -0,0 +1,116 @@
+package mariadb
+
+import (
+	"echodb/internal/command"
+	"echodb/internal/config"
+	cmdCfg "echodb/internal/domain/command-config"
+	"fmt"
+	"strings"
+)
+
+// defaultFlags give a consistent dump of InnoDB tables without locking them
+// and include stored routines, triggers and events.
+const defaultFlags = "--single-transaction --routines --triggers --events"
+
+type MariaDBGenerator struct{}
+
+// Generate returns a logical dump by mariadb-dump for the plain and xml
+// formats and a physical hot backup by mariabackup for xbstream.
+func (g MariaDBGenerator) Generate(data *cmdCfg.ConfigData, settings *config.Settings) command.Command {
+	if data.Port == "" {
+		data.Port = "3306"
+	}
+
+	host := command.DBHost(settings, data)
+
+	var baseCmd, ext string
+	switch data.DumpFormat {
+	case "xbstream":
+		baseCmd = fmt.Sprintf(`mariabackup --defaults-extra-file="$f" --backup --stream=xbstream --user=%s --host=%s --port=%s`,
+			data.User, host, data.Port)
+		ext = "xbstream"
+	case "xml":
+		baseCmd = fmt.Sprintf("mariadb-dump --user=%s --host=%s --port=%s %s --xml", data.User, host, data.Port, defaultFlags)
+		ext = "xml"
+	default:
+		baseCmd = fmt.Sprintf("mariadb-dump --user=%s --host=%s --port=%s %s", data.User, host, data.Port, defaultFlags)
+		ext = "sql"
+	}
+
+	if data.Options != "" {
+		baseCmd += " " + data.Options
+	}
+	if data.DumpFormat != "xbstream" {
+		baseCmd += " " + data.Name
+	}
+
+	if *settings.Archive {
+		baseCmd = command.Pipe(baseCmd, "gzip")
+		ext += ".gz"
+	}
+
+	fileName := fmt.Sprintf("%s.%s", data.DumpName, ext)
+	remotePath := fmt.Sprintf("./%s", fileName)
+
+	cmd := command.Command{
+		Cmd:        baseCmd,
+		RemotePath: remotePath,
+		Env:        command.PasswordEnv("MYSQL_PWD", data.Password),
+	}
+
+	if settings.DumpLocation == "server" {
+		cmd.Cmd = fmt.Sprintf("%s > %s", baseCmd, remotePath)
+	}
+	if data.DumpFormat == "xbstream" {
+		cmd.Cmd = withOptionFile(cmd.Cmd)
+	}
+
+	return cmd
+}
+
+// Restore loads logical dumps with the mariadb client. Physical backups are
+// extracted, prepared and copied back into the data directory, which must be
+// empty with the server stopped, e.g. by hooks.
+func (g MariaDBGenerator) Restore(data *cmdCfg.ConfigData, settings *config.Settings, file string) command.Command {
+	if data.Port == "" {
+		data.Port = "3306"
+	}
+
+	cmd := command.Command{Env: command.PasswordEnv("MYSQL_PWD", data.Password)}
+
+	if strings.HasSuffix(strings.TrimSuffix(file, ".gz"), ".xbstream") {
+		cmd.Cmd = `d=$(mktemp -d) && mbstream -x -C "$d" && mariabackup --prepare --target-dir="$d" && ` +
+			`mariabackup --copy-back --target-dir="$d"; rc=$?; rm -rf "$d"; exit $rc`
+		return cmd
+	}
+
+	client := fmt.Sprintf("mariadb --user=%s --host=127.0.0.1 --port=%s", data.User, data.Port)
+	cmd.Cmd = fmt.Sprintf("%s -e 'CREATE DATABASE IF NOT EXISTS `%s`' && %s %s",
+		client, data.RestoreName(), client, data.RestoreName())
+	return cmd
+}
+
+func (g MariaDBGenerator) Version() string {
+	return "mariadb-dump --version"
+}
+
+func (g MariaDBGenerator) Ping(data *cmdCfg.ConfigData, settings *config.Settings) command.Command {
+	if data.Port == "" {
+		data.Port = "3306"
+	}
+	return command.Command{
+		Cmd: fmt.Sprintf("mariadb --user=%s --host=%s --port=%s -e 'SELECT 1' %s",
+			data.User, command.DBHost(settings, data), data.Port, data.Name),
+		Env: command.PasswordEnv("MYSQL_PWD", data.Password),
+	}
+}
+
+// withOptionFile runs cmd with an option file in $f holding the password
+// from $MYSQL_PWD, as mariabackup does not read the variable.
+func withOptionFile(cmd string) string {
+	return command.WithSecretFile(`[client]\npassword=%s\n`, `"$MYSQL_PWD"`, "", cmd)
+}
+
+func init() {
+	command.Register("mariadb", MariaDBGenerator{})
+}
@@ -0,0 +1,149 @@
+package mongodb
+
+import (
+	"echodb/internal/command"
+	"echodb/internal/config"
+	cmdCfg "echodb/internal/domain/command-config"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type MongoDBGenerator struct{}
+
+// Generate returns a mongodump archive of the database, or of the whole
+// instance with the oplog when oplog is set.
+func (g MongoDBGenerator) Generate(data *cmdCfg.ConfigData, settings *config.Settings) command.Command {
+	opts := data.MongoDB
+
+	args := []string{"mongodump", uriFlag(data, command.DBHost(settings, data))}
+	if data.Password != "" {
+		args = append(args, `--config="$f"`)
+	}
+	if opts.Oplog {
+		args = append(args, "--oplog")
+	} else {
+		args = append(args, "--db="+data.Name)
+		if opts.Collection != "" {
+			args = append(args, "--collection="+opts.Collection)
+		}
+		for _, collection := range opts.ExcludeCollections {
+			args = append(args, "--excludeCollection="+collection)
+		}
+	}
+	args = append(args, "--archive")
+
+	ext := "archive"
+	if *settings.Archive {
+		// The archive is compressed by mongodump and is not a gzip file.
+		args = append(args, "--gzip")
+		ext = "gz.archive"
+	}
+	if data.Options != "" {
+		args = append(args, data.Options)
+	}
+
+	baseCmd := strings.Join(args, " ")
+	fileName := fmt.Sprintf("%s.%s", data.DumpName, ext)
+	remotePath := fmt.Sprintf("./%s", fileName)
+
+	cmd := command.Command{
+		Cmd:        baseCmd,
+		RemotePath: remotePath,
+		Env:        command.PasswordEnv("MONGO_PASSWORD", data.Password),
+	}
+
+	if settings.DumpLocation == "server" {
+		cmd.Cmd = fmt.Sprintf("%s > %s", baseCmd, remotePath)
+	}
+	cmd.Cmd = withConfigFile(cmd.Cmd, data.Password)
+
+	return cmd
+}
+
+// Restore loads the archive with mongorestore, dropping collections before
+// they are restored. With a restore target the namespaces of the database are
+// renamed.
+func (g MongoDBGenerator) Restore(data *cmdCfg.ConfigData, settings *config.Settings, file string) command.Command {
+	args := []string{"mongorestore", uriFlag(data, command.DBHost(settings, data))}
+	if data.Password != "" {
+		args = append(args, `--config="$f"`)
+	}
+	args = append(args, "--archive", "--drop")
+	if strings.HasSuffix(file, ".gz.archive") {
+		args = append(args, "--gzip")
+	}
+	if data.MongoDB.Oplog {
+		args = append(args, "--oplogReplay")
+	} else if data.Target != "" && data.Target != data.Name {
+		args = append(args, fmt.Sprintf("--nsFrom='%s.*' --nsTo='%s.*'", data.Name, data.Target))
+	}
+
+	return command.Command{
+		Cmd: withConfigFile(strings.Join(args, " "), data.Password),
+		Env: command.PasswordEnv("MONGO_PASSWORD", data.Password),
+	}
+}
+
+func (g MongoDBGenerator) Version() string {
+	return "mongodump --version"
+}
+
+// Ping dumps a collection that does not exist, which authenticates with the
+// same tool and credentials as the backup without reading any data.
+func (g MongoDBGenerator) Ping(data *cmdCfg.ConfigData, settings *config.Settings) command.Command {
+	args := []string{"mongodump", uriFlag(data, command.DBHost(settings, data))}
+	if data.Password != "" {
+		args = append(args, `--config="$f"`)
+	}
+	args = append(args, "--db="+data.Name, "--collection=echodb_ping", "--archive > /dev/null")
+
+	return command.Command{
+		Cmd: withConfigFile(strings.Join(args, " "), data.Password),
+		Env: command.PasswordEnv("MONGO_PASSWORD", data.Password),
+	}
+}
+
+// uriFlag returns the --uri flag with the configured URI or one built from
+// the user, host and port. The password is never part of it.
+func uriFlag(data *cmdCfg.ConfigData, host string) string {
+	opts := data.MongoDB
+	if opts.URI != "" {
+		return fmt.Sprintf("--uri='%s'", opts.URI)
+	}
+
+	port := data.Port
+	if port == "" {
+		port = "27017"
+	}
+
+	u := url.URL{Scheme: "mongodb", Host: fmt.Sprintf("%s:%s", host, port), Path: "/"}
+	if data.User != "" {
+		u.User = url.User(data.User)
+	}
+
+	query := url.Values{}
+	if opts.AuthDatabase != "" {
+		query.Set("authSource", opts.AuthDatabase)
+	}
+	if opts.ReplicaSet != "" {
+		query.Set("replicaSet", opts.ReplicaSet)
+	}
+	u.RawQuery = query.Encode()
+
+	return fmt.Sprintf("--uri='%s'", u.String())
+}
+
+// withConfigFile runs cmd with a config file in $f holding the password
+// from $MONGO_PASSWORD, so it is neither part of the URI nor of the command
+// line.
+func withConfigFile(cmd, password string) string {
+	if password == "" {
+		return cmd
+	}
+	return command.WithSecretFile(`password: '%s'\n`, command.YAMLQuotedEnv("MONGO_PASSWORD"), "", cmd)
+}
+
+func init() {
+	command.Register("mongodb", MongoDBGenerator{})
+}
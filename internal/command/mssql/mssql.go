@@ -0,0 +1,110 @@
+package mssql
+
+import (
+	"echodb/internal/command"
+	"echodb/internal/config"
+	cmdCfg "echodb/internal/domain/command-config"
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultBackupDir = "/var/opt/mssql/backup"
+	defaultDataDir   = "/var/opt/mssql/data"
+)
+
+// moveFiles turns the output of RESTORE FILELISTONLY into MOVE clauses that
+// place the data and log files under the names of the restored database.
+const moveFiles = `awk -F'|' -v d=%s -v n=%s 'NF > 2 { ` +
+	`ext = ($3 == "L") ? (j++ ? "_log" j ".ldf" : "_log.ldf") : (i++ ? "_" i ".ndf" : ".mdf"); ` +
+	`printf "%%sMOVE N\047%%s\047 TO N\047%%s/%%s%%s\047", sep, $1, d, n, ext; sep = ", " }'`
+
+type MSSQLGenerator struct{}
+
+// Generate runs a compressed copy-only BACKUP DATABASE into the backup
+// directory of SQL Server. The file is downloaded and removed by the server
+// location flow, so the SSH user needs read and delete rights on it.
+func (g MSSQLGenerator) Generate(data *cmdCfg.ConfigData, settings *config.Settings) command.Command {
+	remotePath := fmt.Sprintf("%s/%s.bak", backupDir(data), data.DumpName)
+
+	query := fmt.Sprintf("BACKUP DATABASE %s TO DISK = %s WITH COPY_ONLY, COMPRESSION, CHECKSUM, INIT, FORMAT",
+		quoteName(data.Name), quoteString(remotePath))
+
+	return command.Command{
+		Cmd:        fmt.Sprintf("%s -Q %s", g.client(data), command.Quote(query)),
+		RemotePath: remotePath,
+		Env:        command.PasswordEnv("SQLCMDPASSWORD", data.Password),
+	}
+}
+
+// Restore writes the uploaded .bak into the backup directory and restores it
+// with its files moved to the names of the target database, replacing the
+// database if it exists.
+func (g MSSQLGenerator) Restore(data *cmdCfg.ConfigData, settings *config.Settings, file string) command.Command {
+	target := data.RestoreName()
+	client := g.client(data)
+
+	fileList := fmt.Sprintf(`%s -h -1 -W -s '|' -Q "SET NOCOUNT ON; RESTORE FILELISTONLY FROM DISK = N'$f'"`, client)
+	moves := fmt.Sprintf(moveFiles, command.Quote(dataDir(data)), command.Quote(target))
+	restore := fmt.Sprintf(`%s -Q "RESTORE DATABASE %s FROM DISK = N'$f' WITH $moves, REPLACE, CHECKSUM, STATS = 10"`,
+		client, quoteName(target))
+
+	return command.Command{
+		Cmd: fmt.Sprintf(`f=%s/echodb_restore_$$.bak && cat > "$f" && chmod 644 "$f" && `+
+			`moves=$(%s | %s) && [ -n "$moves" ] && %s; rc=$?; rm -f "$f"; exit $rc`,
+			command.Quote(backupDir(data)), fileList, moves, restore),
+		Env: command.PasswordEnv("SQLCMDPASSWORD", data.Password),
+	}
+}
+
+// Version prints the header of the sqlcmd usage, as sqlcmd has no version
+// flag. The pipe would exit with the status of head, so a missing sqlcmd is
+// checked first.
+func (g MSSQLGenerator) Version() string {
+	return "command -v sqlcmd > /dev/null && sqlcmd -? | head -n 3"
+}
+
+func (g MSSQLGenerator) Ping(data *cmdCfg.ConfigData, settings *config.Settings) command.Command {
+	return command.Command{
+		Cmd: g.client(data) + " -Q 'SELECT 1'",
+		Env: command.PasswordEnv("SQLCMDPASSWORD", data.Password),
+	}
+}
+
+// client returns the sqlcmd invocation. sqlcmd reads the password from
+// SQLCMDPASSWORD and -b makes it exit with an error when a query fails.
+func (g MSSQLGenerator) client(data *cmdCfg.ConfigData) string {
+	port := data.Port
+	if port == "" {
+		port = "1433"
+	}
+	return fmt.Sprintf("sqlcmd -S 127.0.0.1,%s -U %s -C -b", port, data.User)
+}
+
+func backupDir(data *cmdCfg.ConfigData) string {
+	if data.MSSQL.BackupDir != "" {
+		return strings.TrimSuffix(data.MSSQL.BackupDir, "/")
+	}
+	return defaultBackupDir
+}
+
+func dataDir(data *cmdCfg.ConfigData) string {
+	if data.MSSQL.DataDir != "" {
+		return strings.TrimSuffix(data.MSSQL.DataDir, "/")
+	}
+	return defaultDataDir
+}
+
+// quoteName quotes a T-SQL identifier.
+func quoteName(name string) string {
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+// quoteString quotes a T-SQL string literal.
+func quoteString(s string) string {
+	return "N'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func init() {
+	command.Register("mssql", MSSQLGenerator{})
+}
@@ -7,14 +7,81 @@ import (
 	"fmt"
 )
 
+// defaultFlags give a consistent dump of InnoDB tables without locking them
+// and include stored routines, triggers and events.
+const defaultFlags = "--single-transaction --routines --triggers --events"
+
 type MSQLGenerator struct{}
 
-func (g MSQLGenerator) Generate(data *cmdCfg.ConfigData, settings *config.Settings) (string, string) {
+func (g MSQLGenerator) Generate(data *cmdCfg.ConfigData, settings *config.Settings) command.Command {
+	if data.Port == "" {
+		data.Port = "3306"
+	}
+
+	formatFlag := ""
+	ext := "sql"
+
+	if data.DumpFormat == "xml" {
+		formatFlag = " --xml"
+		ext = "xml"
+	}
+
+	baseCmd := fmt.Sprintf("mysqldump --user=%s --host=%s --port=%s %s%s",
+		data.User, command.DBHost(settings, data), data.Port, defaultFlags, formatFlag)
+
+	if data.Options != "" {
+		baseCmd += " " + data.Options
+	}
+	baseCmd += " " + data.Name
+
+	if *settings.Archive {
+		baseCmd = command.Pipe(baseCmd, "gzip")
+		ext += ".gz"
+	}
+
+	fileName := fmt.Sprintf("%s.%s", data.DumpName, ext)
+	remotePath := fmt.Sprintf("./%s", fileName)
+
+	cmd := command.Command{
+		Cmd:        baseCmd,
+		RemotePath: remotePath,
+		Env:        command.PasswordEnv("MYSQL_PWD", data.Password),
+	}
+
+	if settings.DumpLocation == "server" {
+		cmd.Cmd = fmt.Sprintf("%s > %s", baseCmd, remotePath)
+	}
+
+	return cmd
+}
+
+// Restore creates the database if it is missing and loads the SQL dump.
+func (g MSQLGenerator) Restore(data *cmdCfg.ConfigData, settings *config.Settings, file string) command.Command {
 	if data.Port == "" {
 		data.Port = "3306"
 	}
-	return fmt.Sprintf("mysqldump -u%s -p%s -h127.0.0.1 -P%s %s",
-		data.User, data.Password, data.Port, data.Name), ""
+
+	client := fmt.Sprintf("mysql --user=%s --host=127.0.0.1 --port=%s", data.User, data.Port)
+	return command.Command{
+		Cmd: fmt.Sprintf("%s -e 'CREATE DATABASE IF NOT EXISTS `%s`' && %s %s",
+			client, data.RestoreName(), client, data.RestoreName()),
+		Env: command.PasswordEnv("MYSQL_PWD", data.Password),
+	}
+}
+
+func (g MSQLGenerator) Version() string {
+	return "mysqldump --version"
+}
+
+func (g MSQLGenerator) Ping(data *cmdCfg.ConfigData, settings *config.Settings) command.Command {
+	if data.Port == "" {
+		data.Port = "3306"
+	}
+	return command.Command{
+		Cmd: fmt.Sprintf("mysql --user=%s --host=%s --port=%s -e 'SELECT 1' %s",
+			data.User, command.DBHost(settings, data), data.Port, data.Name),
+		Env: command.PasswordEnv("MYSQL_PWD", data.Password),
+	}
 }
 
 func init() {
@@ -5,19 +5,27 @@ import (
 	"echodb/internal/config"
 	cmdCfg "echodb/internal/domain/command-config"
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 type PSQLGenerator struct{}
 
-func (g PSQLGenerator) Generate(data *cmdCfg.ConfigData, settings *config.Settings) (string, string) {
+func (g PSQLGenerator) Generate(data *cmdCfg.ConfigData, settings *config.Settings) command.Command {
 	if data.Port == "" {
 		data.Port = "5432"
 	}
 
+	if data.Globals || data.DumpFormat == "dumpall" {
+		return g.dumpAll(data, settings)
+	}
+
 	formatFlag := "-Fp" // plain SQL
 	ext := "sql"
 
 	switch data.DumpFormat {
+	case "directory":
+		return g.directory(data, settings)
 	case "dump":
 		formatFlag = "-Fc"
 		ext = "dump"
@@ -26,23 +34,140 @@ func (g PSQLGenerator) Generate(data *cmdCfg.ConfigData, settings *config.Settin
 		ext = "tar"
 	}
 
-	baseCmd := fmt.Sprintf("/usr/bin/pg_dump --dbname=postgresql://%s:%s@127.0.0.1:%s/%s --clean --if-exists --no-owner %s",
-		data.User, data.Password, data.Port, data.Name, formatFlag)
+	baseCmd := fmt.Sprintf("/usr/bin/pg_dump --dbname=postgresql://%s@%s:%s/%s --no-password --clean --if-exists --no-owner %s",
+		data.User, command.DBHost(settings, data), data.Port, data.Name, formatFlag)
+
+	if data.Options != "" {
+		baseCmd += " " + data.Options
+	}
 
 	if *settings.Archive && formatFlag == "-Fp" { // gzip only for plain
-		baseCmd += " | gzip"
+		baseCmd = command.Pipe(baseCmd, "gzip")
 		ext += ".gz"
 	}
 
 	fileName := fmt.Sprintf("%s.%s", data.DumpName, ext)
 	remotePath := fmt.Sprintf("./%s", fileName)
 
+	cmd := command.Command{
+		Cmd:        baseCmd,
+		RemotePath: remotePath,
+		Env:        command.PasswordEnv("PGPASSWORD", data.Password),
+	}
+
+	if settings.DumpLocation == "server" {
+		cmd.Cmd = fmt.Sprintf("%s > %s", baseCmd, remotePath)
+	}
+
+	return cmd
+}
+
+// dumpAll dumps the roles and tablespaces of the server with
+// pg_dumpall --globals-only, or the whole cluster for the dumpall format.
+// The database is only used for the initial connection.
+func (g PSQLGenerator) dumpAll(data *cmdCfg.ConfigData, settings *config.Settings) command.Command {
+	baseCmd := fmt.Sprintf("/usr/bin/pg_dumpall --dbname=postgresql://%s@%s:%s --no-password -l %s",
+		data.User, command.DBHost(settings, data), data.Port, data.Name)
+
+	ext := "cluster.sql"
+	if data.Globals {
+		baseCmd += " --globals-only"
+		ext = "globals.sql"
+	} else if data.Options != "" {
+		baseCmd += " " + data.Options
+	}
+
+	if *settings.Archive {
+		baseCmd = command.Pipe(baseCmd, "gzip")
+		ext += ".gz"
+	}
+
+	remotePath := fmt.Sprintf("./%s.%s", data.DumpName, ext)
+
+	cmd := command.Command{
+		Cmd:        baseCmd,
+		RemotePath: remotePath,
+		Env:        command.PasswordEnv("PGPASSWORD", data.Password),
+	}
+
 	if settings.DumpLocation == "server" {
-		return fmt.Sprintf("%s > %s", baseCmd, remotePath), remotePath
+		cmd.Cmd = fmt.Sprintf("%s > %s", baseCmd, remotePath)
 	}
 
-	return baseCmd, remotePath
+	return cmd
+}
+
+// directory dumps with parallel jobs into a temporary directory and writes
+// it as a tar stream. The files of the directory format are compressed by
+// pg_dump, so the stream is not gzipped.
+func (g PSQLGenerator) directory(data *cmdCfg.ConfigData, settings *config.Settings) command.Command {
+	baseCmd := fmt.Sprintf(`/usr/bin/pg_dump --dbname=postgresql://%s@%s:%s/%s --no-password --clean --if-exists --no-owner -Fd -j %s -f "$d/%s"`,
+		data.User, command.DBHost(settings, data), data.Port, data.Name, jobs(data), data.Name)
+	if data.Options != "" {
+		baseCmd += " " + data.Options
+	}
 
+	remotePath := fmt.Sprintf("./%s.dir.tar", data.DumpName)
+	archive := fmt.Sprintf(`tar -C "$d" -cf - %s`, data.Name)
+	if settings.DumpLocation == "server" {
+		archive += " > " + remotePath
+	}
+
+	return command.Command{
+		Cmd:        fmt.Sprintf(`d=$(mktemp -d) && %s && %s; rc=$?; rm -rf "$d"; exit $rc`, baseCmd, archive),
+		RemotePath: remotePath,
+		Env:        command.PasswordEnv("PGPASSWORD", data.Password),
+	}
+}
+
+// Restore runs psql for plain SQL dumps and pg_restore for custom, tar and
+// directory archives. Directory archives are extracted and restored with
+// parallel jobs. Globals and cluster dumps of pg_dumpall are run against the
+// postgres database without stopping on errors, as some roles usually exist.
+func (g PSQLGenerator) Restore(data *cmdCfg.ConfigData, settings *config.Settings, file string) command.Command {
+	if data.Port == "" {
+		data.Port = "5432"
+	}
+
+	dbURL := fmt.Sprintf("postgresql://%s@127.0.0.1:%s/%s", data.User, data.Port, data.RestoreName())
+	cmd := fmt.Sprintf("psql --dbname=%s --no-password --set ON_ERROR_STOP=1 --quiet", dbURL)
+	if ext := strings.TrimSuffix(file, ".gz"); strings.HasSuffix(ext, ".globals.sql") || strings.HasSuffix(ext, ".cluster.sql") {
+		cmd = fmt.Sprintf("psql --dbname=postgresql://%s@127.0.0.1:%s/postgres --no-password --quiet", data.User, data.Port)
+	} else if strings.HasSuffix(ext, ".dir.tar") {
+		cmd = fmt.Sprintf(`d=$(mktemp -d) && tar -C "$d" -xf - && /usr/bin/pg_restore --dbname=%s --no-password --clean --if-exists --no-owner -j %s "$d"/*; rc=$?; rm -rf "$d"; exit $rc`,
+			dbURL, jobs(data))
+	} else if strings.HasSuffix(ext, ".dump") || strings.HasSuffix(ext, ".tar") {
+		cmd = fmt.Sprintf("/usr/bin/pg_restore --dbname=%s --no-password --clean --if-exists --no-owner", dbURL)
+	}
+
+	return command.Command{
+		Cmd: cmd,
+		Env: command.PasswordEnv("PGPASSWORD", data.Password),
+	}
+}
+
+func (g PSQLGenerator) Version() string {
+	return "/usr/bin/pg_dump --version"
+}
+
+func (g PSQLGenerator) Ping(data *cmdCfg.ConfigData, settings *config.Settings) command.Command {
+	if data.Port == "" {
+		data.Port = "5432"
+	}
+	return command.Command{
+		Cmd: fmt.Sprintf("psql --dbname=postgresql://%s@%s:%s/%s --no-password -tAc 'SELECT 1'",
+			data.User, command.DBHost(settings, data), data.Port, data.Name),
+		Env: command.PasswordEnv("PGPASSWORD", data.Password),
+	}
+}
+
+// jobs returns the number of parallel jobs, by default the number of CPUs
+// of the host running the tool.
+func jobs(data *cmdCfg.ConfigData) string {
+	if data.Jobs > 0 {
+		return strconv.Itoa(data.Jobs)
+	}
+	return "$(nproc)"
 }
 
 func init() {
@@ -0,0 +1,80 @@
+package redis
+
+import (
+	"echodb/internal/command"
+	"echodb/internal/config"
+	cmdCfg "echodb/internal/domain/command-config"
+	"fmt"
+)
+
+// saveTimeout is how many seconds a snapshot may take before the backup
+// gives up waiting for LASTSAVE to change.
+const saveTimeout = 3600
+
+type RedisGenerator struct{}
+
+// Generate triggers BGSAVE, waits until LASTSAVE changes and copies the RDB
+// file for the server location. Other locations stream a snapshot with
+// `redis-cli --rdb -`.
+func (g RedisGenerator) Generate(data *cmdCfg.ConfigData, settings *config.Settings) command.Command {
+	client := g.client(data, command.DBHost(settings, data))
+
+	// read returns the command writing the snapshot read by cmd.
+	read := func(cmd string) string { return cmd }
+	ext := "rdb"
+	if *settings.Archive {
+		read = func(cmd string) string { return command.Pipe(cmd, "gzip") }
+		ext += ".gz"
+	}
+
+	fileName := fmt.Sprintf("%s.%s", data.DumpName, ext)
+	remotePath := fmt.Sprintf("./%s", fileName)
+
+	cmd := command.Command{
+		RemotePath: remotePath,
+		Env:        command.PasswordEnv("REDISCLI_AUTH", data.Password),
+	}
+
+	if settings.DumpLocation != "server" {
+		cmd.Cmd = read(client + " --rdb -")
+		return cmd
+	}
+
+	cmd.Cmd = fmt.Sprintf(`c="%s" && before=$($c LASTSAVE) && $c BGSAVE > /dev/null && i=0 && `+
+		`while [ "$($c LASTSAVE)" = "$before" ]; do i=$((i+1)); [ $i -lt %d ] || exit 1; sleep 1; done && `+
+		`$c INFO persistence | grep -q '^rdb_last_bgsave_status:ok' && `+
+		`dir=$($c --raw CONFIG GET dir | sed -n 2p) && file=$($c --raw CONFIG GET dbfilename | sed -n 2p) && `+
+		`%s > %s`,
+		client, saveTimeout, read(`cat "$dir/$file"`), remotePath)
+	return cmd
+}
+
+func (g RedisGenerator) Version() string {
+	return "redis-cli --version"
+}
+
+func (g RedisGenerator) Ping(data *cmdCfg.ConfigData, settings *config.Settings) command.Command {
+	return command.Command{
+		Cmd: g.client(data, command.DBHost(settings, data)) + " PING",
+		Env: command.PasswordEnv("REDISCLI_AUTH", data.Password),
+	}
+}
+
+// client returns the redis-cli invocation. The password is read by
+// redis-cli from REDISCLI_AUTH, the ACL user is given with --user.
+func (g RedisGenerator) client(data *cmdCfg.ConfigData, host string) string {
+	port := data.Port
+	if port == "" {
+		port = "6379"
+	}
+
+	client := fmt.Sprintf("redis-cli -h %s -p %s --no-auth-warning", host, port)
+	if data.User != "" {
+		client += " --user " + data.User
+	}
+	return client
+}
+
+func init() {
+	command.Register("redis", RedisGenerator{})
+}
@@ -5,8 +5,28 @@ import (
 	cmdCfg "echodb/internal/domain/command-config"
 )
 
+// Command is a shell command run on the server.
+type Command struct {
+	Cmd        string
+	RemotePath string
+	// Env holds credentials exported in the remote shell before Cmd runs.
+	// They are passed over stdin and never appear on a command line.
+	Env map[string]string
+}
+
 type CmdGenerator interface {
-	Generate(*cmdCfg.ConfigData, *config.Settings) (cmd string, remotePath string)
+	Generate(*cmdCfg.ConfigData, *config.Settings) Command
+	// Version returns a command printing the version of the dump binary.
+	Version() string
+	// Ping returns a command running a trivial query with the database
+	// credentials, from where the dump tool runs.
+	Ping(*cmdCfg.ConfigData, *config.Settings) Command
+}
+
+// Restorer is implemented by generators whose dumps can be restored. The
+// returned command reads the dump named file from stdin.
+type Restorer interface {
+	Restore(data *cmdCfg.ConfigData, settings *config.Settings, file string) Command
 }
 
 var generators = map[string]CmdGenerator{}
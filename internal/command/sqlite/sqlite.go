@@ -0,0 +1,72 @@
+package sqlite
+
+import (
+	"echodb/internal/command"
+	"echodb/internal/config"
+	cmdCfg "echodb/internal/domain/command-config"
+	"fmt"
+)
+
+type SQLiteGenerator struct{}
+
+// Generate copies the database file into a temporary directory with the
+// online backup API, or with VACUUM INTO for the vacuum format, so the copy
+// is consistent while the database is in use.
+func (g SQLiteGenerator) Generate(data *cmdCfg.ConfigData, settings *config.Settings) command.Command {
+	copyCmd := fmt.Sprintf(`sqlite3 %s ".backup '$d/db'"`, command.Quote(data.Path))
+	if data.DumpFormat == "vacuum" {
+		copyCmd = fmt.Sprintf(`sqlite3 %s "VACUUM INTO '$d/db'"`, command.Quote(data.Path))
+	}
+
+	readCmd := `cat "$d/db"`
+	ext := "sqlite"
+	if *settings.Archive {
+		readCmd = `gzip -c "$d/db"`
+		ext += ".gz"
+	}
+
+	fileName := fmt.Sprintf("%s.%s", data.DumpName, ext)
+	remotePath := fmt.Sprintf("./%s", fileName)
+
+	if settings.DumpLocation == "server" {
+		readCmd = fmt.Sprintf("%s > %s", readCmd, remotePath)
+	}
+
+	return command.Command{
+		Cmd:        fmt.Sprintf(`d=$(mktemp -d) && %s && %s; rc=$?; rm -rf "$d"; exit $rc`, copyCmd, readCmd),
+		RemotePath: remotePath,
+	}
+}
+
+// Restore writes the uploaded copy next to the database file, checks its
+// integrity and moves it over the file, so readers see either the old or the
+// new database. Writers should be stopped by pre_restore hooks. The target of
+// a restore is another file path.
+func (g SQLiteGenerator) Restore(data *cmdCfg.ConfigData, settings *config.Settings, file string) command.Command {
+	path := command.Quote(data.Path)
+	if data.Target != "" {
+		path = command.Quote(data.Target)
+	}
+
+	return command.Command{
+		Cmd: fmt.Sprintf(`t=$(mktemp %s.restore.XXXXXX) && cat > "$t" && `+
+			`[ "$(sqlite3 "$t" 'PRAGMA integrity_check')" = ok ] && `+
+			`{ [ ! -e %s ] || chmod "$(stat -c %%a %s)" "$t"; } && `+
+			`rm -f %s-wal %s-shm && mv -f "$t" %s; rc=$?; rm -f "$t"; exit $rc`,
+			path, path, path, path, path, path),
+	}
+}
+
+func (g SQLiteGenerator) Version() string {
+	return "sqlite3 --version"
+}
+
+func (g SQLiteGenerator) Ping(data *cmdCfg.ConfigData, settings *config.Settings) command.Command {
+	return command.Command{
+		Cmd: fmt.Sprintf("sqlite3 -readonly %s 'SELECT count(*) FROM sqlite_master'", command.Quote(data.Path)),
+	}
+}
+
+func init() {
+	command.Register("sqlite", SQLiteGenerator{})
+}
@@ -2,42 +2,185 @@ package config
 
 import (
 	"fmt"
-	"os"
-
-	"github.com/creasty/defaults"
-	"github.com/go-playground/validator/v10"
-	"gopkg.in/yaml.v3"
+	"time"
 )
 
+// DriverFormats lists the dump formats every driver supports.
+var DriverFormats = map[string][]string{
+	"psql":    {"plain", "dump", "tar", "directory", "dumpall"},
+	"mysql":   {"plain", "xml"},
+	"mariadb": {"plain", "xml", "xbstream"},
+	"sqlite":  {"plain", "vacuum"},
+}
+
+// DriverLocations lists the locations of drivers that do not support all
+// of them.
+var DriverLocations = map[string][]string{
+	"sqlite":     {"server", "local-ssh"},
+	"mssql":      {"server"},
+	"clickhouse": {"server"},
+}
+
+// GlobalsName is the database name of the postgres.globals backup in file
+// names and reports. psql databases cannot use it, so the archive pattern of
+// a database never matches the globals dumps.
+const GlobalsName = "_globals"
+
+// keyNamedDrivers lists the drivers whose databases are named after their
+// key when name is not set. Their commands do not use the name, and the
+// user does not tell two of them on a server apart.
+var keyNamedDrivers = map[string]bool{
+	"sqlite": true,
+	"redis":  true,
+}
+
 type Config struct {
 	Settings  Settings            `yaml:"settings" validate:"required" json:"settings"`
-	Databases map[string]Database `yaml:"databases" validate:"required" json:"databases,omitempty"`
-	Servers   map[string]Server   `yaml:"servers" validate:"required" json:"servers,omitempty"`
+	Databases map[string]Database `yaml:"databases" validate:"required,dive" json:"databases,omitempty"`
+	Servers   map[string]Server   `yaml:"servers" validate:"required,dive" json:"servers,omitempty"`
+	Groups    map[string]Group    `yaml:"groups,omitempty" json:"groups,omitempty"`
 	Licence   string              `json:"licence,omitempty"`
+	// Include lists glob patterns of further configuration files, relative to
+	// the file that includes them. Resolved by Load.
+	Include []string `yaml:"include,omitempty" json:"include,omitempty"`
 }
 
 type Settings struct {
 	SSH          SSHConfig `yaml:"ssh"`
-	Template     string    `yaml:"template" default:"{%srv%}_{%db%}_{%time%}"`
+	Template     string    `yaml:"template" default:"{%srv%}_{%db%}_{%time%}" secret:"-"`
 	Archive      *bool     `yaml:"archive" default:"true"`
-	Driver       string    `yaml:"driver" validate:"required"`
+	Driver       string    `yaml:"driver,omitempty" validate:"omitempty,oneof=psql mysql mariadb mongodb sqlite redis mssql clickhouse"`
 	DBPort       string    `yaml:"db_port,omitempty"`
 	SrvKey       string    `yaml:"server_key,omitempty"`
 	SrvPost      string    `yaml:"server_port,omitempty"`
-	DumpLocation string    `yaml:"location" default:"server"` // server, local-ssh, local-direct
-	DumpFormat   string    `yaml:"format" default:"plain"`    // plain, dump, tar
+	DumpLocation string    `yaml:"location" default:"server" validate:"oneof=server local-ssh local-direct"`
+	DumpFormat   string    `yaml:"format" default:"plain" validate:"oneof=plain dump tar directory dumpall xml xbstream vacuum"`
 	DirDump      string    `yaml:"dir_dump" default:"./"`
 	DirArchived  string    `yaml:"dir_archived" default:"./archived"`
 	Logging      *bool     `yaml:"logging" default:"false"`
+	// MaxParallelServers limits how many servers are processed at once, 0 means no limit.
+	MaxParallelServers int `yaml:"max_parallel_servers" default:"0" validate:"gte=0"`
+	MaxParallelDumps   int `yaml:"max_parallel_dumps" default:"1" validate:"gte=1"`
+	// MaxParallelTransfers limits how many backups run at once on all servers together, 0 means no limit.
+	MaxParallelTransfers int    `yaml:"max_parallel_transfers" default:"0" validate:"gte=0"`
+	Retry                Retry  `yaml:"retry"`
+	Notify               Notify `yaml:"notify"`
+	Hooks                Hooks  `yaml:"hooks"`
+}
+
+// Hooks are commands run around the backup and the restore of a database.
+// Hooks from settings run before the hooks of the database.
+type Hooks struct {
+	Pre     []Hook `yaml:"pre,omitempty" validate:"dive"`
+	Post    []Hook `yaml:"post,omitempty" validate:"dive"`
+	OnError []Hook `yaml:"on_error,omitempty" validate:"dive"`
+	// PostRestore runs after every restore, successful or not.
+	PreRestore  []Hook `yaml:"pre_restore,omitempty" validate:"dive"`
+	PostRestore []Hook `yaml:"post_restore,omitempty" validate:"dive"`
+}
+
+type Hook struct {
+	Command string        `yaml:"command" validate:"required" secret:"-"`
+	Run     string        `yaml:"run" default:"remote" validate:"oneof=remote local"`
+	Timeout time.Duration `yaml:"timeout" default:"1m"`
+}
+
+// Notify configures the messages sent when a run or a database finishes.
+type Notify struct {
+	OnFailureOnly *bool           `yaml:"on_failure_only" default:"false"`
+	PerDatabase   *bool           `yaml:"per_database" default:"false"`
+	Template      string          `yaml:"template,omitempty" secret:"-"` // text/template for the message body
+	Channels      []NotifyChannel `yaml:"channels,omitempty" validate:"dive"`
+}
+
+type NotifyChannel struct {
+	Type    string        `yaml:"type" validate:"required,oneof=webhook slack email command"`
+	URL     string        `yaml:"url,omitempty"`                // webhook, slack
+	Command string        `yaml:"command,omitempty" secret:"-"` // command
+	Timeout time.Duration `yaml:"timeout" default:"30s"`
+	// email
+	Host     string   `yaml:"host,omitempty"`
+	Port     string   `yaml:"port,omitempty"`
+	Username string   `yaml:"username,omitempty"`
+	Password string   `yaml:"password,omitempty"`
+	From     string   `yaml:"from,omitempty"`
+	To       []string `yaml:"to,omitempty"`
+}
+
+// Retry configures how often transient failures of a backup stage are retried.
+type Retry struct {
+	MaxAttempts int           `yaml:"max_attempts" default:"1" validate:"gte=1"`
+	Backoff     time.Duration `yaml:"backoff" default:"5s"`
+	MaxBackoff  time.Duration `yaml:"max_backoff" default:"1m"`
+	Stages      []string      `yaml:"stages" default:"[\"connect\",\"dump\",\"download\",\"upload\"]" validate:"dive,oneof=connect dump download upload"`
 }
 
 type Database struct {
-	User     string `yaml:"user"`
-	Password string `yaml:"password"`
-	Name     string `yaml:"name,omitempty"`
-	Server   string `yaml:"server" validate:"required"`
-	Key      string `yaml:"key"`
-	Port     string `yaml:"port,omitempty"`
+	User     string   `yaml:"user"`
+	Password string   `yaml:"password"`
+	Name     string   `yaml:"name,omitempty"`
+	Server   string   `yaml:"server" validate:"required"`
+	Key      string   `yaml:"key"`
+	Port     string   `yaml:"port,omitempty"`
+	Schedule string   `yaml:"schedule,omitempty"` // cron expression used by the daemon
+	Tags     []string `yaml:"tags,omitempty"`
+	Hooks    Hooks    `yaml:"hooks"`
+	// Overrides of the settings with the same keys.
+	Driver       string `yaml:"driver,omitempty" validate:"omitempty,oneof=psql mysql mariadb mongodb sqlite redis mssql clickhouse"`
+	DumpFormat   string `yaml:"format,omitempty" validate:"omitempty,oneof=plain dump tar directory dumpall xml xbstream vacuum"`
+	DumpLocation string `yaml:"location,omitempty" validate:"omitempty,oneof=server local-ssh local-direct"`
+	Template     string `yaml:"template,omitempty" secret:"-"`
+	Archive      *bool  `yaml:"archive,omitempty"`
+	// DumpOptions are extra arguments appended to the dump command.
+	DumpOptions string `yaml:"dump_options,omitempty" secret:"-"`
+	// Jobs is the number of parallel jobs of the psql directory format.
+	Jobs     int      `yaml:"jobs,omitempty" validate:"omitempty,gte=1"`
+	MongoDB  MongoDB  `yaml:"mongodb,omitempty"`
+	Postgres Postgres `yaml:"postgres,omitempty"`
+	// Path is the database file of the sqlite driver.
+	Path       string     `yaml:"path,omitempty"`
+	MSSQL      MSSQL      `yaml:"mssql,omitempty"`
+	ClickHouse ClickHouse `yaml:"clickhouse,omitempty"`
+}
+
+// ClickHouse holds the options of the clickhouse driver.
+type ClickHouse struct {
+	// Tool is auto, native or clickhouse-backup. auto uses clickhouse-backup
+	// when it is installed on the server.
+	Tool string `yaml:"tool,omitempty" validate:"omitempty,oneof=auto native clickhouse-backup"`
+	// BackupDir must be listed in backups.allowed_path of the server.
+	BackupDir string   `yaml:"backup_dir,omitempty"`
+	Tables    []string `yaml:"tables,omitempty"` // all tables of the database when empty
+}
+
+// MSSQL holds the options of the mssql driver.
+type MSSQL struct {
+	BackupDir string `yaml:"backup_dir,omitempty"` // written by SQL Server, default /var/opt/mssql/backup
+	DataDir   string `yaml:"data_dir,omitempty"`   // restored database files, default /var/opt/mssql/data
+}
+
+// Postgres holds the options of the psql driver.
+type Postgres struct {
+	Globals bool `yaml:"globals,omitempty"` // back up roles and tablespaces of the server once per run
+}
+
+// MongoDB holds the options of the mongodb driver.
+type MongoDB struct {
+	URI          string `yaml:"uri,omitempty"` // replaces the URI built from user, host and port, without password
+	AuthDatabase string `yaml:"auth_database,omitempty"`
+	ReplicaSet   string `yaml:"replica_set,omitempty"`
+	// Oplog dumps the whole instance with the oplog for a point-in-time
+	// snapshot. It cannot be combined with a collection selection.
+	Oplog              bool     `yaml:"oplog,omitempty"`
+	Collection         string   `yaml:"collection,omitempty"`
+	ExcludeCollections []string `yaml:"exclude_collections,omitempty"`
+}
+
+// Group selects databases by key and by tag.
+type Group struct {
+	Databases []string `yaml:"databases,omitempty"`
+	Tags      []string `yaml:"tags,omitempty"`
+	Schedule  string   `yaml:"schedule,omitempty"` // cron expression used by the daemon
 }
 
 type Server struct {
@@ -47,6 +190,8 @@ type Server struct {
 	Port     string `yaml:"port,omitempty"`
 	SSHKey   string `yaml:"key,omitempty"`
 	Password string `yaml:"password,omitempty"`
+	// MaxParallelDumps overrides settings.max_parallel_dumps for this server.
+	MaxParallelDumps int `yaml:"max_parallel_dumps,omitempty" validate:"gte=0"`
 }
 
 type SSHConfig struct {
@@ -55,24 +200,16 @@ type SSHConfig struct {
 	IsPassphrase *bool  `yaml:"is_passphrase" validate:"required"`
 }
 
-func Load(filename string) (*Config, error) {
-	data, err := os.ReadFile(filename)
+// Load reads the configuration from a file or from every *.yaml and *.yml
+// file of a directory, following `include` patterns. Secret references are
+// resolved. A configuration with problems returns a *ValidationError.
+func Load(path string) (*Config, error) {
+	config, issues, err := load(path, true)
 	if err != nil {
 		return nil, err
 	}
-
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, err
-	}
-
-	if err := defaults.Set(&config); err != nil {
-		return nil, err
-	}
-
-	validate := validator.New()
-	if err := validate.Struct(&config); err != nil {
-		return nil, fmt.Errorf("config validation failed: %w", err)
+	if len(issues) > 0 {
+		return nil, &ValidationError{Issues: issues}
 	}
 
 	for k, server := range config.Servers {
@@ -82,7 +219,37 @@ func Load(filename string) (*Config, error) {
 		}
 	}
 
-	return &config, nil
+	for k, db := range config.Databases {
+		if db.Name == "" && keyNamedDrivers[config.Settings.ForDatabase(db).Driver] {
+			db.Name = k
+			config.Databases[k] = db
+		}
+	}
+
+	return config, nil
+}
+
+// ForDatabase returns the settings with the overrides of the database
+// applied. The global db_port belongs to the global driver, so it is
+// dropped for a database with another driver.
+func (s Settings) ForDatabase(db Database) Settings {
+	if db.Driver != "" && db.Driver != s.Driver {
+		s.Driver = db.Driver
+		s.DBPort = ""
+	}
+	if db.DumpFormat != "" {
+		s.DumpFormat = db.DumpFormat
+	}
+	if db.DumpLocation != "" {
+		s.DumpLocation = db.DumpLocation
+	}
+	if db.Template != "" {
+		s.Template = db.Template
+	}
+	if db.Archive != nil {
+		s.Archive = db.Archive
+	}
+	return s
 }
 
 func (s Server) GetDisplayName() string {
@@ -92,6 +259,13 @@ func (s Server) GetDisplayName() string {
 	return s.Host
 }
 
+func (s Server) GetMaxParallelDumps(dumps int) int {
+	if s.MaxParallelDumps > 0 {
+		return s.MaxParallelDumps
+	}
+	return dumps
+}
+
 func (s Server) GetPort(port string) string {
 	if s.Port != "" {
 		return s.Port
@@ -106,6 +280,36 @@ func (d Database) GetDisplayName() string {
 	return d.User
 }
 
+func (d Database) HasTag(tag string) bool {
+	for _, t := range d.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupDatabases returns the keys of the databases listed in the group or
+// carrying one of its tags.
+func (c *Config) GroupDatabases(name string) ([]string, error) {
+	group, ok := c.Groups[name]
+	if !ok {
+		return nil, fmt.Errorf("group %s not found", name)
+	}
+
+	keys := append([]string{}, group.Databases...)
+	for key, db := range c.Databases {
+		for _, tag := range group.Tags {
+			if db.HasTag(tag) {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+
+	return keys, nil
+}
+
 func (d Database) GetPort(port string) string {
 	if d.Port != "" {
 		return d.Port
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadNamesDatabasesAfterKey(t *testing.T) {
+	config := `settings:
+  driver: psql
+  ssh:
+    is_passphrase: false
+servers:
+  srv:
+    host: 10.0.0.1
+    user: backup
+databases:
+  app:
+    user: shop
+    server: srv
+  orders:
+    driver: sqlite
+    path: /var/lib/shop/orders.db
+    server: srv
+  stock:
+    driver: sqlite
+    name: inventory
+    path: /var/lib/shop/stock.db
+    server: srv
+  cache:
+    driver: redis
+    user: default
+    server: srv
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]string{"app": "shop", "orders": "orders", "stock": "inventory", "cache": "cache"} {
+		if got := cfg.Databases[key].GetDisplayName(); got != want {
+			t.Errorf("database %s is named %q, want %q", key, got, want)
+		}
+	}
+}
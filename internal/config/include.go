@@ -0,0 +1,279 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const includeKey = "include"
+
+// mapSections are the top-level sections whose entries are merged across
+// files. A key may be defined in only one file.
+var mapSections = map[string]bool{
+	"servers":   true,
+	"databases": true,
+	"groups":    true,
+}
+
+type document struct {
+	file string
+	root *yaml.Node
+}
+
+// source is the merged configuration and the file every node was read from.
+type source struct {
+	root  *yaml.Node
+	files map[*yaml.Node]string
+}
+
+// position returns where a node was defined. Nodes created by the merge
+// report the position of their first child.
+func (s *source) position(node *yaml.Node) (string, int, int) {
+	for node != nil {
+		if file, ok := s.files[node]; ok {
+			return file, node.Line, node.Column
+		}
+		if len(node.Content) == 0 {
+			break
+		}
+		node = node.Content[0]
+	}
+	return "", 0, 0
+}
+
+type origin struct {
+	file string
+	line int
+}
+
+func (o origin) String() string {
+	return fmt.Sprintf("%s:%d", o.file, o.line)
+}
+
+// readConfig reads a configuration file or every *.yaml and *.yml file of a
+// directory together with the files they include, and merges them into one
+// YAML mapping. Files are merged in order: files of a directory by name,
+// each file followed by its includes.
+func readConfig(path string) (*source, error) {
+	files, err := configFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []document
+	seen := make(map[string]bool)
+	for _, file := range files {
+		if docs, err = readDocument(file, seen, docs); err != nil {
+			return nil, err
+		}
+	}
+
+	root, err := mergeDocuments(docs)
+	if err != nil {
+		return nil, err
+	}
+
+	src := &source{root: root, files: make(map[*yaml.Node]string)}
+	for _, doc := range docs {
+		src.addFile(doc.root, doc.file)
+	}
+	return src, nil
+}
+
+func (s *source) addFile(node *yaml.Node, file string) {
+	s.files[node] = file
+	for _, child := range node.Content {
+		s.addFile(child, file)
+	}
+}
+
+func configFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(path, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no *.yaml or *.yml files in %s", path)
+	}
+	return files, nil
+}
+
+// readDocument appends the file and, after it, the files matched by its
+// `include` patterns. Patterns are relative to the including file. Files that
+// were already read are skipped.
+func readDocument(file string, seen map[string]bool, docs []document) ([]document, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	if seen[abs] {
+		return docs, nil
+	}
+	seen[abs] = true
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if len(root.Content) == 0 {
+		return docs, nil
+	}
+
+	mapping := root.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s:%d: configuration must be a mapping", file, mapping.Line)
+	}
+	docs = append(docs, document{file: file, root: mapping})
+
+	patterns, err := includePatterns(file, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(file), pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: include %s: %w", file, pattern, err)
+		}
+		if len(matches) == 0 && !hasMeta(pattern) {
+			return nil, fmt.Errorf("%s: include %s: file not found", file, pattern)
+		}
+
+		for _, match := range matches {
+			if docs, err = readDocument(match, seen, docs); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return docs, nil
+}
+
+func includePatterns(file string, mapping *yaml.Node) ([]string, error) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != includeKey {
+			continue
+		}
+
+		value := mapping.Content[i+1]
+		var patterns []string
+		if value.Kind == yaml.ScalarNode && value.Tag != "!!null" {
+			patterns = []string{value.Value}
+		} else if err := value.Decode(&patterns); err != nil {
+			return nil, fmt.Errorf("%s:%d: include must be a list of file patterns", file, value.Line)
+		}
+		return patterns, nil
+	}
+	return nil, nil
+}
+
+// mergeDocuments merges the entries of servers, databases and groups and
+// reports keys defined more than once. Settings are merged key by key, later
+// files override earlier ones.
+func mergeDocuments(docs []document) (*yaml.Node, error) {
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	defined := make(map[string]origin)
+
+	for _, doc := range docs {
+		for i := 0; i+1 < len(doc.root.Content); i += 2 {
+			key, value := doc.root.Content[i], doc.root.Content[i+1]
+			if key.Value == includeKey || value.Tag == "!!null" {
+				continue
+			}
+
+			if value.Kind != yaml.MappingNode && (mapSections[key.Value] || key.Value == "settings") {
+				return nil, fmt.Errorf("%s:%d: %s must be a mapping", doc.file, value.Line, key.Value)
+			}
+
+			if !mapSections[key.Value] {
+				setValue(merged, key, value)
+				continue
+			}
+
+			section := mappingValue(merged, key)
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				entry := value.Content[j]
+				id := key.Value + "." + entry.Value
+				at := origin{file: doc.file, line: entry.Line}
+				if prev, ok := defined[id]; ok {
+					return nil, fmt.Errorf("%s: %s is already defined in %s", at, id, prev)
+				}
+				defined[id] = at
+				section.Content = append(section.Content, entry, value.Content[j+1])
+			}
+		}
+	}
+
+	return merged, nil
+}
+
+// setValue sets key in the mapping. Mappings are merged recursively, any
+// other value replaces the previous one.
+func setValue(mapping, key, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != key.Value {
+			continue
+		}
+
+		prev := mapping.Content[i+1]
+		if prev.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+			merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: prev.Line, Column: prev.Column}
+			merged.Content = append(merged.Content, prev.Content...)
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				setValue(merged, value.Content[j], value.Content[j+1])
+			}
+			mapping.Content[i+1] = merged
+		} else {
+			mapping.Content[i+1] = value
+		}
+		return
+	}
+
+	mapping.Content = append(mapping.Content, key, value)
+}
+
+// mappingValue returns the mapping stored under key, adding an empty one if
+// the key is missing.
+func mappingValue(mapping, key *yaml.Node) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key.Value {
+			return mapping.Content[i+1]
+		}
+	}
+
+	value := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: key.Line, Column: key.Column}
+	mapping.Content = append(mapping.Content, key, value)
+	return value
+}
+
+func hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
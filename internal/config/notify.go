@@ -0,0 +1,61 @@
+package config
+
+import (
+	"echodb/internal/report"
+	"fmt"
+	"net/url"
+	"text/template"
+	"time"
+)
+
+// ParseNotifyTemplate parses the text/template of notification messages
+// with the functions available in it.
+func ParseNotifyTemplate(text string) (*template.Template, error) {
+	return template.New("notify").Funcs(template.FuncMap{
+		"size": report.FormatSize,
+		"duration": func(d time.Duration) string {
+			return d.Round(time.Millisecond).String()
+		},
+	}).Parse(text)
+}
+
+// checkNotify reports an invalid message template and channels missing the
+// fields their type needs.
+func (v *checker) checkNotify(notify Notify) {
+	if notify.Template != "" {
+		if _, err := ParseNotifyTemplate(notify.Template); err != nil {
+			v.addPath("settings.notify.template", err.Error())
+		}
+	}
+
+	for i, ch := range notify.Channels {
+		path := fmt.Sprintf("settings.notify.channels[%d]", i)
+		required := func(name, value string) {
+			if value == "" {
+				v.addPath(path+"."+name, fmt.Sprintf("is required for %s channels", ch.Type))
+			}
+		}
+
+		switch ch.Type {
+		case "webhook", "slack":
+			required("url", ch.URL)
+			// The URL may hold a secret, so it is never part of the message.
+			if ch.URL != "" && !isSecretReference(ch.URL) && !isHTTPURL(ch.URL) {
+				v.addPath(path+".url", "must be an http or https URL")
+			}
+		case "email":
+			required("host", ch.Host)
+			required("from", ch.From)
+			if len(ch.To) == 0 {
+				v.addPath(path+".to", "is required for email channels")
+			}
+		case "command":
+			required("command", ch.Command)
+		}
+	}
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const durationPattern = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+
+// Schema returns a JSON Schema of a configuration file, generated from the
+// yaml, default and validate tags. Top-level sections are not required, so
+// the schema also fits files that are only included.
+func Schema() ([]byte, error) {
+	schema := schemaFor(reflect.TypeOf(Config{}))
+	delete(schema, "required")
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "echodb configuration"
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+func schemaFor(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == durationType {
+		return map[string]any{"type": "string", "pattern": durationPattern}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := make(map[string]any)
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name := fieldName(field)
+			property := schemaFor(field.Type)
+			if value, ok := defaultValue(field); ok {
+				property["default"] = value
+			}
+			if rules := field.Tag.Get("validate"); rules != "" {
+				if applyRules(property, rules) {
+					required = append(required, name)
+				}
+			}
+			properties[name] = property
+		}
+
+		schema := map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
+
+// applyRules adds the constraints of a validate tag to the schema and
+// reports whether the field is required. Rules after `dive` apply to the
+// items of a list or map.
+func applyRules(schema map[string]any, rules string) bool {
+	required, dived := false, false
+	target := schema
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			dived = true
+			if items, ok := target["items"].(map[string]any); ok {
+				target = items
+			} else if items, ok := target["additionalProperties"].(map[string]any); ok {
+				target = items
+			}
+		case "required":
+			required = required || !dived
+		case "oneof":
+			target["enum"] = strings.Fields(param)
+		case "gte":
+			if n, err := strconv.Atoi(param); err == nil {
+				target["minimum"] = n
+			}
+		}
+	}
+	return required
+}
+
+func defaultValue(field reflect.StructField) (any, bool) {
+	value, ok := field.Tag.Lookup("default")
+	if !ok {
+		return nil, false
+	}
+
+	t := field.Type
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == durationType {
+		return value, true
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		return b, err == nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.Atoi(value)
+		return n, err == nil
+	case reflect.Slice, reflect.Map:
+		var v any
+		err := json.Unmarshal([]byte(value), &v)
+		return v, err == nil
+	default:
+		return value, true
+	}
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"context"
+	"echodb/pkg/utils"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	filePrefix = "file:"
+	cmdPrefix  = "cmd:"
+
+	secretCmdTimeout = 30 * time.Second
+)
+
+// resolveSecrets replaces references in every string field of the config:
+// `${NAME}` is substituted with an environment variable (`$${NAME}` keeps the
+// text as is), `file:/path` is replaced with the content of the file and
+// `cmd:command` with the output of the command. Resolved values never appear
+// in errors. Fields tagged `secret:"-"`, such as hook commands and templates,
+// are kept as is: they run in a shell that expands its own variables.
+func resolveSecrets(cfg *Config) error {
+	return resolveValue(reflect.ValueOf(cfg).Elem(), "")
+}
+
+func resolveValue(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.String:
+		resolved, err := resolveString(v.String())
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		v.SetString(resolved)
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() || field.Tag.Get("secret") == "-" {
+				continue
+			}
+			if err := resolveValue(v.Field(i), joinPath(path, fieldName(field))); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Pointer:
+		if !v.IsNil() {
+			return resolveValue(v.Elem(), path)
+		}
+
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := resolveValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(iter.Value().Type()).Elem()
+			elem.Set(iter.Value())
+			if err := resolveValue(elem, joinPath(path, fmt.Sprint(iter.Key().Interface()))); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	}
+
+	return nil
+}
+
+// isSecretReference reports whether s is resolved when the configuration
+// is loaded. config validate checks the unresolved values, so the content of
+// a reference is only known after loading.
+func isSecretReference(s string) bool {
+	return strings.HasPrefix(s, filePrefix) || strings.HasPrefix(s, cmdPrefix) || strings.Contains(s, "${")
+}
+
+func resolveString(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, filePrefix):
+		path := strings.TrimSpace(strings.TrimPrefix(s, filePrefix))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file %s: %w", path, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+
+	case strings.HasPrefix(s, cmdPrefix):
+		command := strings.TrimSpace(strings.TrimPrefix(s, cmdPrefix))
+		ctx, cancel := context.WithTimeout(context.Background(), secretCmdTimeout)
+		defer cancel()
+
+		cmd := utils.ShellCommand(ctx, command)
+		cmd.Stderr = os.Stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("secret command %q failed: %w", command, err)
+		}
+		return strings.TrimRight(string(output), "\r\n"), nil
+	}
+
+	return expandEnv(s)
+}
+
+func expandEnv(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1])
+			b.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+
+		end := strings.Index(s[i:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated ${ in value")
+		}
+
+		name := s[i+2 : i+end]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+
+		b.WriteString(s[:i])
+		b.WriteString(value)
+		s = s[i+end+1:]
+	}
+}
+
+func fieldName(field reflect.StructField) string {
+	if tag := strings.Split(field.Tag.Get("yaml"), ",")[0]; tag != "" && tag != "-" {
+		return tag
+	}
+	return strings.ToLower(field.Name)
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,440 @@
+package config
+
+import (
+	"echodb/internal/schedule"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/creasty/defaults"
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Issue is a problem found in the configuration.
+type Issue struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	var b strings.Builder
+	if i.File != "" {
+		b.WriteString(i.File)
+		if i.Line > 0 {
+			fmt.Fprintf(&b, ":%d:%d", i.Line, i.Column)
+		}
+		b.WriteString(": ")
+	}
+	if i.Path != "" {
+		b.WriteString(i.Path)
+		b.WriteString(": ")
+	}
+	b.WriteString(i.Message)
+	return b.String()
+}
+
+// ValidationError lists every problem that made the configuration invalid.
+type ValidationError struct {
+	Issues []Issue
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Issues)+1)
+	lines = append(lines, "config validation failed:")
+	for _, issue := range e.Issues {
+		lines = append(lines, "  "+issue.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate checks the configuration at path without resolving secret
+// references and returns every problem found. The error is set when the
+// files cannot be read or parsed.
+func Validate(path string) ([]Issue, error) {
+	_, issues, err := load(path, false)
+	return issues, err
+}
+
+func load(path string, resolve bool) (*Config, []Issue, error) {
+	src, err := readConfig(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	v := &checker{src: src}
+	v.checkNode(src.root, reflect.TypeOf(Config{}), "")
+
+	var config Config
+	if err := src.root.Decode(&config); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, nil, err
+		}
+		if len(v.issues) == 0 {
+			v.add(src.root, "", err.Error())
+		}
+	}
+
+	if resolve {
+		if err := resolveSecrets(&config); err != nil {
+			return nil, nil, fmt.Errorf("failed to resolve secrets: %w", err)
+		}
+	}
+
+	if err := defaults.Set(&config); err != nil {
+		return nil, nil, err
+	}
+
+	v.checkStruct(&config)
+	v.checkReferences(&config)
+
+	sort.SliceStable(v.issues, func(i, j int) bool {
+		a, b := v.issues[i], v.issues[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return &config, v.issues, nil
+}
+
+type checker struct {
+	src    *source
+	issues []Issue
+}
+
+func (v *checker) add(node *yaml.Node, path, message string) {
+	file, line, column := v.src.position(node)
+	v.issues = append(v.issues, Issue{File: file, Line: line, Column: column, Path: path, Message: message})
+}
+
+// checkNode reports unknown keys and values that do not fit the type of the
+// field they are decoded into.
+func (v *checker) checkNode(node *yaml.Node, t reflect.Type, path string) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Tag == "!!null" {
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			v.add(node, path, "must be a mapping")
+			return
+		}
+
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := joinPath(path, key.Value)
+			field, ok := fields[key.Value]
+			if !ok {
+				v.add(key, keyPath, unknownKey(key.Value, fields))
+				continue
+			}
+			v.checkNode(value, field.Type, keyPath)
+		}
+
+	case t.Kind() == reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			v.add(node, path, "must be a mapping")
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v.checkNode(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value))
+		}
+
+	case t.Kind() == reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			v.add(node, path, "must be a list")
+			return
+		}
+		for i, item := range node.Content {
+			v.checkNode(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+
+	default:
+		if node.Kind != yaml.ScalarNode {
+			v.add(node, path, "must be "+typeName(t))
+			return
+		}
+		if err := node.Decode(reflect.New(t).Interface()); err != nil {
+			v.add(node, path, fmt.Sprintf("must be %s, got %q", typeName(t), node.Value))
+		}
+	}
+}
+
+// checkStruct runs the `validate` tags of the configuration.
+func (v *checker) checkStruct(config *Config) {
+	err := validator.New().Struct(config)
+	if err == nil {
+		return
+	}
+
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		v.add(v.src.root, "", err.Error())
+		return
+	}
+
+	for _, fe := range errs {
+		path, node := v.locate(fe.StructNamespace())
+		v.add(node, path, validationMessage(fe))
+	}
+}
+
+// checkReferences reports keys pointing at servers, databases and schedules
+// that do not exist or cannot be parsed, driver options that conflict and
+// incomplete notifications.
+func (v *checker) checkReferences(config *Config) {
+	v.checkNotify(config.Settings.Notify)
+
+	for _, key := range sortedKeys(config.Databases) {
+		db := config.Databases[key]
+		if _, ok := config.Servers[db.Server]; db.Server != "" && !ok {
+			v.addPath(fmt.Sprintf("databases.%s.server", key), fmt.Sprintf("server %q is not defined in servers", db.Server))
+		}
+		settings := config.Settings.ForDatabase(db)
+		if settings.Driver == "" {
+			v.addPath(fmt.Sprintf("databases.%s.driver", key), "is required when settings.driver is not set")
+		} else if formats := DriverFormats[settings.Driver]; formats != nil && !slices.Contains(formats, settings.DumpFormat) {
+			path := "settings.format"
+			if db.DumpFormat != "" {
+				path = fmt.Sprintf("databases.%s.format", key)
+			}
+			v.addPath(path, fmt.Sprintf("format %q is not supported by driver %s of database %s, use one of %s",
+				settings.DumpFormat, settings.Driver, key, strings.Join(formats, ", ")))
+		}
+		if locations := DriverLocations[settings.Driver]; locations != nil && !slices.Contains(locations, settings.DumpLocation) {
+			path := "settings.location"
+			if db.DumpLocation != "" {
+				path = fmt.Sprintf("databases.%s.location", key)
+			}
+			v.addPath(path, fmt.Sprintf("location %q is not supported by driver %s of database %s, use one of %s",
+				settings.DumpLocation, settings.Driver, key, strings.Join(locations, ", ")))
+		}
+		if settings.Driver == "mariadb" && settings.DumpFormat == "xbstream" && settings.DumpLocation == "local-direct" {
+			path := "settings.location"
+			if db.DumpLocation != "" {
+				path = fmt.Sprintf("databases.%s.location", key)
+			}
+			v.addPath(path, fmt.Sprintf("location \"local-direct\" cannot be used with format xbstream of database %s, mariabackup must run on the database host", key))
+		}
+		if settings.Driver == "sqlite" && db.Path == "" {
+			v.addPath(fmt.Sprintf("databases.%s.path", key), "is required by the sqlite driver")
+		}
+		if settings.Driver == "psql" && db.GetDisplayName() == GlobalsName {
+			v.addPath(fmt.Sprintf("databases.%s.name", key), fmt.Sprintf("%q is reserved for the postgres.globals backup", GlobalsName))
+		}
+		if db.Postgres.Globals && settings.Driver != "psql" {
+			v.addPath(fmt.Sprintf("databases.%s.postgres.globals", key), "is only supported by the psql driver")
+		}
+		if db.MongoDB.Oplog && (db.MongoDB.Collection != "" || len(db.MongoDB.ExcludeCollections) > 0) {
+			v.addPath(fmt.Sprintf("databases.%s.mongodb.oplog", key), "cannot be combined with collection or exclude_collections")
+		}
+		if db.Schedule != "" {
+			if _, err := schedule.Parse(db.Schedule); err != nil {
+				v.addPath(fmt.Sprintf("databases.%s.schedule", key), err.Error())
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(config.Groups) {
+		group := config.Groups[name]
+		for i, key := range group.Databases {
+			if _, ok := config.Databases[key]; !ok {
+				v.addPath(fmt.Sprintf("groups.%s.databases[%d]", name, i), fmt.Sprintf("database %q is not defined in databases", key))
+			}
+		}
+		if group.Schedule != "" {
+			if _, err := schedule.Parse(group.Schedule); err != nil {
+				v.addPath(fmt.Sprintf("groups.%s.schedule", name), err.Error())
+			}
+		}
+	}
+}
+
+func (v *checker) addPath(path, message string) {
+	v.add(v.lookup(path), path, message)
+}
+
+// lookup returns the node at a dotted path, or the deepest existing parent.
+func (v *checker) lookup(path string) *yaml.Node {
+	node := v.src.root
+	for _, part := range strings.Split(path, ".") {
+		name, index := part, -1
+		if i := strings.IndexByte(part, '['); i >= 0 && strings.HasSuffix(part, "]") {
+			name = part[:i]
+			index, _ = strconv.Atoi(part[i+1 : len(part)-1])
+		}
+
+		child := mappingChild(node, name)
+		if child == nil {
+			return node
+		}
+		node = child
+
+		if index >= 0 {
+			if node.Kind != yaml.SequenceNode || index >= len(node.Content) {
+				return node
+			}
+			node = node.Content[index]
+		}
+	}
+	return node
+}
+
+// locate translates a validator namespace such as
+// `Config.Databases[app].Server` to the YAML path and node it refers to.
+func (v *checker) locate(namespace string) (string, *yaml.Node) {
+	t := reflect.TypeOf(Config{})
+	var path string
+
+	parts := strings.Split(namespace, ".")
+	for _, part := range parts[1:] {
+		name, index := part, ""
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			name, index = part[:i], strings.Trim(part[i:], "[]")
+		}
+
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		field, ok := t.FieldByName(name)
+		if !ok {
+			break
+		}
+		path = joinPath(path, fieldName(field))
+		t = field.Type
+
+		if index != "" {
+			for t.Kind() == reflect.Pointer {
+				t = t.Elem()
+			}
+			if t.Kind() == reflect.Map {
+				path = joinPath(path, index)
+			} else {
+				path = fmt.Sprintf("%s[%s]", path, index)
+			}
+			t = t.Elem()
+		}
+	}
+
+	return path, v.lookup(path)
+}
+
+func mappingChild(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "oneof":
+		// The value may be a resolved secret, so it is never part of the message.
+		return fmt.Sprintf("must be one of %s", strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "gte":
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	default:
+		return fmt.Sprintf("failed the %q check", fe.Tag())
+	}
+}
+
+func typeName(t reflect.Type) string {
+	if t == durationType {
+		return "a duration like 30s or 5m"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.String:
+		return "a string"
+	default:
+		return "a " + t.Kind().String()
+	}
+}
+
+// yamlFields maps the YAML keys of a struct to its fields.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.IsExported() {
+			fields[fieldName(field)] = field
+		}
+	}
+	return fields
+}
+
+func unknownKey(key string, fields map[string]reflect.StructField) string {
+	best, bestDistance := "", 3
+	for name := range fields {
+		if d := distance(key, name); d < bestDistance || d == bestDistance && name < best {
+			best, bestDistance = name, d
+		}
+	}
+	if best != "" {
+		return fmt.Sprintf("unknown key %q, did you mean %q?", key, best)
+	}
+	return fmt.Sprintf("unknown key %q", key)
+}
+
+// distance is the Levenshtein distance between two strings.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
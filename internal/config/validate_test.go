@@ -0,0 +1,133 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a configuration with one psql database and settings
+// extended by settings, indented as keys of the settings block.
+func writeConfig(t *testing.T, settings string) string {
+	t.Helper()
+
+	config := `settings:
+  driver: psql
+  ssh:
+    is_passphrase: false
+` + settings + `servers:
+  srv:
+    host: 10.0.0.1
+    user: backup
+databases:
+  app:
+    user: app
+    server: srv
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateAcceptsSecretReferenceURLs(t *testing.T) {
+	path := writeConfig(t, `  notify:
+    channels:
+      - type: slack
+        url: ${SLACK_URL}
+      - type: webhook
+        url: file:/run/secrets/webhook
+      - type: webhook
+        url: "cmd:cat /run/secrets/webhook"
+`)
+
+	issues, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, issue := range issues {
+		t.Errorf("unexpected issue: %s", issue)
+	}
+}
+
+func TestValidateRejectsNonHTTPURL(t *testing.T) {
+	path := writeConfig(t, `  notify:
+    channels:
+      - type: webhook
+        url: ftp://example.com/hook
+`)
+
+	issues, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || issues[0].Path != "settings.notify.channels[0].url" {
+		t.Fatalf("got issues %v, want one for the url", issues)
+	}
+	if strings.Contains(issues[0].Message, "example.com") {
+		t.Errorf("message contains the URL: %s", issues[0].Message)
+	}
+}
+
+func TestLoadDoesNotPrintResolvedURL(t *testing.T) {
+	t.Setenv("ECHODB_TEST_SLACK_URL", "hooks.slack.com/services/T0/B0/SECRETTOKEN")
+	path := writeConfig(t, `  notify:
+    channels:
+      - type: slack
+        url: ${ECHODB_TEST_SLACK_URL}
+`)
+
+	_, err := Load(path)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("got error %v, want a validation error", err)
+	}
+	if strings.Contains(err.Error(), "SECRETTOKEN") {
+		t.Errorf("error contains the resolved URL: %v", err)
+	}
+}
+
+func TestLoadDoesNotPrintResolvedEnumValue(t *testing.T) {
+	t.Setenv("ECHODB_TEST_LOCATION", "SECRETVALUE")
+	path := writeConfig(t, `  location: ${ECHODB_TEST_LOCATION}
+`)
+
+	_, err := Load(path)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("got error %v, want a validation error", err)
+	}
+	if !strings.Contains(err.Error(), "must be one of server, local-ssh, local-direct") {
+		t.Errorf("error does not list the allowed values: %v", err)
+	}
+	if strings.Contains(err.Error(), "SECRETVALUE") {
+		t.Errorf("error contains the resolved value: %v", err)
+	}
+}
+
+func TestValidateReservesGlobalsName(t *testing.T) {
+	path := writeConfig(t, "")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, []byte(`  roles:
+    user: app
+    name: _globals
+    server: srv
+`)...)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	issues, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || issues[0].Path != "databases.roles.name" {
+		t.Errorf("got issues %v, want one for the name", issues)
+	}
+}
@@ -1,14 +1,25 @@
 package connect
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/term"
 )
 
+// ErrAuth is returned when the SSH credentials cannot be loaded or are
+// rejected by the server. Retrying does not help.
+var ErrAuth = errors.New("ssh authentication failed")
+
 type Connect struct {
 	Server           string
 	Username         string
@@ -81,17 +92,42 @@ func (c *Connect) buildSSHConfig() (*ssh.ClientConfig, error) {
 }
 
 func (c *Connect) Connect() error {
+	return c.ConnectContext(context.Background())
+}
+
+// ConnectContext connects like Connect and gives up when ctx is done, also
+// during the SSH handshake.
+func (c *Connect) ConnectContext(ctx context.Context) error {
 	config, err := c.buildSSHConfig()
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %w", ErrAuth, err)
 	}
 
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%s", c.Server, c.Port), config)
+	addr := fmt.Sprintf("%s:%s", c.Server, c.Port)
+	conn, err := (&net.Dialer{Timeout: config.Timeout}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect via SSH: %w", err)
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if !stop() {
+		if err == nil {
+			_ = clientConn.Close()
+		}
+		return fmt.Errorf("failed to connect via SSH: %w", ctx.Err())
+	}
 	if err != nil {
+		_ = conn.Close()
+		if strings.Contains(err.Error(), "unable to authenticate") {
+			return fmt.Errorf("%w: %w", ErrAuth, err)
+		}
 		return fmt.Errorf("failed to connect via SSH: %w", err)
 	}
 
-	c.client = client
+	c.client = ssh.NewClient(clientConn, chans, reqs)
 	return nil
 }
 
@@ -115,6 +151,135 @@ func (c *Connect) RunCommand(cmd string) (string, error) {
 	return string(output), err
 }
 
+// RunCommandEnv runs cmd after exporting env in the remote shell. The values
+// are written to the session's stdin and read by the shell, so they never
+// show up in the process list or shell history of the server.
+func (c *Connect) RunCommandEnv(cmd string, env map[string]string) (string, error) {
+	if len(env) == 0 {
+		return c.RunCommand(cmd)
+	}
+
+	wrapped, stdin, err := exportEnv(cmd, env)
+	if err != nil {
+		return "", err
+	}
+
+	session, err := c.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer func(session *ssh.Session) {
+		_ = session.Close()
+	}(session)
+
+	session.Stdin = strings.NewReader(stdin)
+	output, err := session.CombinedOutput(wrapped)
+	return string(output), err
+}
+
+// StreamCommandEnv runs cmd like RunCommandEnv with input written to its
+// stdin after the env values and its stdout copied to stdout. When stdout is
+// nil, stdout is returned together with stderr.
+func (c *Connect) StreamCommandEnv(cmd string, env map[string]string, input io.Reader, stdout io.Writer) (string, error) {
+	wrapped, stdin := cmd, ""
+	if len(env) > 0 {
+		var err error
+		if wrapped, stdin, err = exportEnv(cmd, env); err != nil {
+			return "", err
+		}
+	}
+
+	session, err := c.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer func(session *ssh.Session) {
+		_ = session.Close()
+	}(session)
+
+	if input == nil {
+		input = strings.NewReader("")
+	}
+	session.Stdin = io.MultiReader(strings.NewReader(stdin), input)
+
+	var output bytes.Buffer
+	session.Stdout = stdout
+	if stdout == nil {
+		session.Stdout = &output
+	}
+	session.Stderr = &output
+
+	err = session.Run(wrapped)
+	return output.String(), err
+}
+
+// exportEnv prefixes cmd with shell reads of the env values from stdin and
+// returns the wrapped command and the stdin content.
+func exportEnv(cmd string, env map[string]string) (string, string, error) {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var prefix, stdin strings.Builder
+	for _, name := range names {
+		value := env[name]
+		if strings.ContainsAny(value, "\r\n") {
+			return "", "", fmt.Errorf("value of %s must not contain line breaks", name)
+		}
+		fmt.Fprintf(&prefix, "IFS= read -r %s && export %s && ", name, name)
+		stdin.WriteString(value + "\n")
+	}
+
+	return fmt.Sprintf("%s{ %s; }", prefix.String(), cmd), stdin.String(), nil
+}
+
+// RunCommandContext runs cmd like RunCommand and kills it when ctx is done.
+func (c *Connect) RunCommandContext(ctx context.Context, cmd string) (string, error) {
+	return c.RunCommandEnvContext(ctx, cmd, nil)
+}
+
+// RunCommandEnvContext runs cmd like RunCommandEnv and kills it when ctx is
+// done.
+func (c *Connect) RunCommandEnvContext(ctx context.Context, cmd string, env map[string]string) (string, error) {
+	wrapped, stdin := cmd, ""
+	if len(env) > 0 {
+		var err error
+		if wrapped, stdin, err = exportEnv(cmd, env); err != nil {
+			return "", err
+		}
+	}
+
+	session, err := c.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer func(session *ssh.Session) {
+		_ = session.Close()
+	}(session)
+
+	session.Stdin = strings.NewReader(stdin)
+
+	type result struct {
+		output []byte
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := session.CombinedOutput(wrapped)
+		done <- result{output, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		_ = session.Signal(ssh.SIGKILL)
+		return "", ctx.Err()
+	case r := <-done:
+		return string(r.output), r.err
+	}
+}
+
 func (c *Connect) TestConnection() error {
 	_, err := c.RunCommand("true")
 	return err
@@ -0,0 +1,229 @@
+package doctor
+
+import (
+	"context"
+	"echodb/internal/command"
+	"echodb/internal/connect"
+	"echodb/pkg/logging"
+	"echodb/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// minFreeBytes is the free space below which a disk check is reported as failed.
+const minFreeBytes = 1 << 30
+
+type Check struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail"`
+}
+
+type Report struct {
+	Database string  `json:"database"`
+	Server   string  `json:"server"`
+	Passed   bool    `json:"passed"`
+	Checks   []Check `json:"checks"`
+}
+
+type Doctor struct {
+	ctx        context.Context
+	conn       *connect.Connect
+	versionCmd string
+	pingCmd    command.Command
+	localDir   string
+	// local is set when the dump tool runs on this machine, as with the
+	// local-direct location.
+	local bool
+	// dumpDir is the directory of the server the dump is written to, empty
+	// when the dump streams to this machine.
+	dumpDir string
+}
+
+// New returns a doctor checking a database. conn is nil when the backup
+// does not connect to the server.
+func New(
+	ctx context.Context,
+	conn *connect.Connect,
+	versionCmd string,
+	pingCmd command.Command,
+	localDir string,
+	local bool,
+	dumpDir string,
+) *Doctor {
+	return &Doctor{
+		ctx:        ctx,
+		conn:       conn,
+		versionCmd: versionCmd,
+		pingCmd:    pingCmd,
+		localDir:   localDir,
+		local:      local,
+		dumpDir:    dumpDir,
+	}
+}
+
+// Run executes every check in order. The dump tool and the credentials are
+// checked where the backup runs them, the free space of the server in the
+// directory the dump is written to. Checks that need the SSH session are
+// skipped once connecting to the server has failed. The connection and the
+// commands are abandoned when the context is done.
+func (d *Doctor) Run(database, server string) Report {
+	report := Report{Database: database, Server: server}
+
+	connected := false
+	if d.conn == nil {
+		d.skip(&report, "not used by the backup", "connect", "ssh")
+	} else if connected = d.add(&report, "connect", "connected", d.conn.ConnectContext(d.ctx)); connected {
+		defer func(conn *connect.Connect) {
+			_ = conn.Close()
+		}(d.conn)
+
+		_, err := d.conn.RunCommandContext(d.ctx, "true")
+		d.add(&report, "ssh", "command executed", err)
+	} else {
+		report.Checks = append(report.Checks, Check{Name: "ssh", Detail: "skipped: not connected"})
+	}
+
+	switch {
+	case d.local:
+		version, err := d.runLocal(d.versionCmd, nil)
+		d.add(&report, "dump binary", strings.TrimSpace(version), commandErr(version, err))
+
+		output, err := d.runLocal(d.pingCmd.Cmd, d.pingCmd.Env)
+		d.add(&report, "credentials", "query executed", commandErr(output, err))
+	case connected:
+		version, err := d.conn.RunCommandContext(d.ctx, d.versionCmd)
+		d.add(&report, "dump binary", strings.TrimSpace(version), commandErr(version, err))
+
+		output, err := d.conn.RunCommandEnvContext(d.ctx, d.pingCmd.Cmd, d.pingCmd.Env)
+		d.add(&report, "credentials", "query executed", commandErr(output, err))
+	default:
+		for _, name := range []string{"dump binary", "credentials"} {
+			report.Checks = append(report.Checks, Check{Name: name, Detail: "skipped: not connected"})
+		}
+	}
+
+	switch {
+	case d.dumpDir == "":
+		d.skip(&report, "the dump is written on this machine", "server disk")
+	case connected:
+		free, err := d.serverDiskFree()
+		d.add(&report, "server disk", formatFree(free), checkFree(free, err))
+	default:
+		report.Checks = append(report.Checks, Check{Name: "server disk", Detail: "skipped: not connected"})
+	}
+
+	free, err := utils.DiskFree(d.localDir)
+	d.add(&report, "local disk", formatFree(free), checkFree(free, err))
+
+	report.Passed = true
+	for _, check := range report.Checks {
+		report.Passed = report.Passed && check.Passed
+	}
+
+	return report
+}
+
+func (d *Doctor) add(report *Report, name, detail string, err error) bool {
+	check := Check{Name: name, Passed: err == nil, Detail: detail}
+	if err != nil {
+		check.Detail = err.Error()
+		logging.L(d.ctx).Warn(
+			"Check failed",
+			logging.StringAttr("check", name),
+			logging.StringAttr("server", report.Server),
+			logging.StringAttr("database", report.Database),
+			logging.ErrAttr(err),
+		)
+	}
+	report.Checks = append(report.Checks, check)
+	return check.Passed
+}
+
+// skip adds checks that do not apply to the backup. They pass.
+func (d *Doctor) skip(report *Report, reason string, names ...string) {
+	for _, name := range names {
+		report.Checks = append(report.Checks, Check{Name: name, Passed: true, Detail: "skipped: " + reason})
+	}
+}
+
+// runLocal runs cmd on this machine with env added to the environment.
+func (d *Doctor) runLocal(cmd string, env map[string]string) (string, error) {
+	c := utils.ShellCommand(d.ctx, cmd)
+	c.Env = os.Environ()
+	for name, value := range env {
+		c.Env = append(c.Env, name+"="+value)
+	}
+	output, err := c.CombinedOutput()
+	return string(output), err
+}
+
+func (d *Doctor) serverDiskFree() (uint64, error) {
+	output, err := d.conn.RunCommandContext(d.ctx, "df -Pk "+command.Quote(d.dumpDir))
+	if err != nil {
+		return 0, commandErr(output, err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 4 {
+		return 0, fmt.Errorf("unexpected df output: %s", strings.TrimSpace(output))
+	}
+
+	kb, err := strconv.ParseUint(fields[3], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected df output: %s", strings.TrimSpace(output))
+	}
+	return kb * 1024, nil
+}
+
+func commandErr(output string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if output = strings.TrimSpace(output); output != "" {
+		return fmt.Errorf("%v: %s", err, output)
+	}
+	return err
+}
+
+func checkFree(free uint64, err error) error {
+	if err != nil {
+		return err
+	}
+	if free < minFreeBytes {
+		return fmt.Errorf("only %s available", formatFree(free))
+	}
+	return nil
+}
+
+func formatFree(free uint64) string {
+	return fmt.Sprintf("%.1f GiB free", float64(free)/(1<<30))
+}
+
+func PrintTable(w io.Writer, reports []Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "SERVER\tDATABASE\tCHECK\tSTATUS\tDETAIL")
+	for _, report := range reports {
+		for _, check := range report.Checks {
+			status := "FAIL"
+			if check.Passed {
+				status = "PASS"
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+				report.Server, report.Database, check.Name, status, check.Detail)
+		}
+	}
+	return tw.Flush()
+}
+
+func PrintJSON(w io.Writer, reports []Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reports)
+}
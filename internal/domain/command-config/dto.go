@@ -1,5 +1,7 @@
 package command_config
 
+import "echodb/internal/config"
+
 type ConfigData struct {
 	User       string
 	Password   string
@@ -9,4 +11,23 @@ type ConfigData struct {
 	Host       string
 	DumpName   string
 	DumpFormat string
+	Options    string
+	Jobs       int
+	Postgres   config.Postgres
+	// Globals selects the pg_dumpall --globals-only backup of the server.
+	Globals bool
+	// Target is the database a restore writes into, Name when empty.
+	Target     string
+	MongoDB    config.MongoDB
+	Path       string
+	MSSQL      config.MSSQL
+	ClickHouse config.ClickHouse
+}
+
+// RestoreName returns the database a restore writes into.
+func (c *ConfigData) RestoreName() string {
+	if c.Target != "" {
+		return c.Target
+	}
+	return c.Name
 }
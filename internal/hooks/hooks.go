@@ -0,0 +1,132 @@
+package hooks
+
+import (
+	"context"
+	"echodb/internal/config"
+	"echodb/internal/connect"
+	"echodb/pkg/logging"
+	"echodb/pkg/utils"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	StagePre     = "pre"
+	StagePost    = "post"
+	StageOnError = "on_error"
+
+	StagePreRestore  = "pre_restore"
+	StagePostRestore = "post_restore"
+)
+
+// Vars are substituted into hook commands as {%srv%}, {%db%}, {%dump%},
+// {%file%} and {%status%}.
+type Vars struct {
+	Server    string
+	Database  string
+	DumpPath  string
+	LocalPath string
+	Status    string
+}
+
+// Expand substitutes the variables into a hook command.
+func (v Vars) Expand(cmd string) string {
+	return strings.NewReplacer(
+		"{%srv%}", v.Server,
+		"{%db%}", v.Database,
+		"{%dump%}", v.DumpPath,
+		"{%file%}", v.LocalPath,
+		"{%status%}", v.Status,
+	).Replace(cmd)
+}
+
+func (v Vars) env() []string {
+	return []string{
+		"ECHODB_SERVER=" + v.Server,
+		"ECHODB_DATABASE=" + v.Database,
+		"ECHODB_DUMP=" + v.DumpPath,
+		"ECHODB_FILE=" + v.LocalPath,
+		"ECHODB_STATUS=" + v.Status,
+	}
+}
+
+type Runner struct {
+	ctx  context.Context
+	conn *connect.Connect
+}
+
+func New(ctx context.Context, conn *connect.Connect) *Runner {
+	return &Runner{ctx: ctx, conn: conn}
+}
+
+// Run executes the hooks of a stage in order and stops at the first failure.
+func (r *Runner) Run(stage string, hooks []config.Hook, vars Vars) error {
+	for i, hook := range hooks {
+		cmd := vars.Expand(hook.Command)
+
+		logging.L(r.ctx).Info(
+			"Running hook",
+			logging.StringAttr("stage", stage),
+			logging.IntAttr("index", i),
+			logging.StringAttr("run", hook.Run),
+			logging.StringAttr("database", vars.Database),
+		)
+		fmt.Printf("Running %s hook %d (%s)\n", stage, i+1, hook.Run)
+
+		output, err := r.run(hook, cmd, vars)
+		if err != nil {
+			if output = strings.TrimSpace(output); output != "" {
+				err = fmt.Errorf("%w: %s", err, output)
+			}
+			logging.L(r.ctx).Error(
+				"Hook failed",
+				logging.StringAttr("stage", stage),
+				logging.IntAttr("index", i),
+				logging.ErrAttr(err),
+			)
+			return fmt.Errorf("%s hook %d failed: %w", stage, i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Runner) run(hook config.Hook, cmd string, vars Vars) (string, error) {
+	ctx, cancel := context.WithTimeout(r.ctx, hook.Timeout)
+	defer cancel()
+
+	if hook.Run == "local" {
+		c := utils.ShellCommand(ctx, cmd)
+		c.Env = append(os.Environ(), vars.env()...)
+		output, err := c.CombinedOutput()
+		return string(output), err
+	}
+
+	return r.conn.RunCommandContext(ctx, cmd)
+}
+
+// Merge returns the hooks of settings followed by the hooks of the database.
+func Merge(settings, database config.Hooks) config.Hooks {
+	return config.Hooks{
+		Pre:     append(append([]config.Hook{}, settings.Pre...), database.Pre...),
+		Post:    append(append([]config.Hook{}, settings.Post...), database.Post...),
+		OnError: append(append([]config.Hook{}, settings.OnError...), database.OnError...),
+
+		PreRestore:  append(append([]config.Hook{}, settings.PreRestore...), database.PreRestore...),
+		PostRestore: append(append([]config.Hook{}, settings.PostRestore...), database.PostRestore...),
+	}
+}
+
+// NeedsConnection reports whether any of the backup hooks runs on the
+// server.
+func NeedsConnection(hooks config.Hooks) bool {
+	for _, stage := range [][]config.Hook{hooks.Pre, hooks.Post, hooks.OnError} {
+		for _, hook := range stage {
+			if hook.Run != "local" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,311 @@
+package metrics
+
+import (
+	"bufio"
+	"echodb/internal/report"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type key struct {
+	server   string
+	database string
+}
+
+type databaseMetrics struct {
+	duration         float64
+	dumpDuration     float64
+	downloadDuration float64
+	size             float64
+	throughput       float64
+	lastSuccess      float64
+	successes        uint64
+	failures         uint64
+}
+
+// Registry keeps the latest backup metrics per database and renders them in
+// the Prometheus text exposition format. It is safe for concurrent use.
+type Registry struct {
+	mu        sync.Mutex
+	databases map[key]*databaseMetrics
+}
+
+func New() *Registry {
+	return &Registry{databases: make(map[key]*databaseMetrics)}
+}
+
+// Observe records the result of a database backup. Cancelled backups are
+// ignored.
+func (r *Registry) Observe(result report.Result) {
+	if r == nil || result.Status == report.StatusCancelled {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := key{server: result.Server, database: result.Database}
+	m, ok := r.databases[k]
+	if !ok {
+		m = &databaseMetrics{}
+		r.databases[k] = m
+	}
+
+	if result.Status != report.StatusSuccess {
+		m.failures++
+		return
+	}
+
+	m.successes++
+	m.duration = result.Duration.Seconds()
+	m.dumpDuration = result.DumpDuration.Seconds()
+	m.downloadDuration = result.DownloadDuration.Seconds()
+	m.size = float64(result.Size)
+	m.throughput = 0
+	if m.downloadDuration > 0 {
+		m.throughput = m.size / m.downloadDuration
+	}
+	m.lastSuccess = float64(time.Now().Unix())
+}
+
+type metric struct {
+	name  string
+	kind  string
+	help  string
+	value func(*databaseMetrics) float64
+	set   func(*databaseMetrics, float64)
+}
+
+var definitions = []metric{
+	{"echodb_backup_duration_seconds", "gauge", "Duration of the last successful backup.",
+		func(m *databaseMetrics) float64 { return m.duration },
+		func(m *databaseMetrics, v float64) { m.duration = v }},
+	{"echodb_backup_dump_duration_seconds", "gauge", "Duration of the dump stage of the last successful backup.",
+		func(m *databaseMetrics) float64 { return m.dumpDuration },
+		func(m *databaseMetrics, v float64) { m.dumpDuration = v }},
+	{"echodb_backup_download_duration_seconds", "gauge", "Duration of the download stage of the last successful backup.",
+		func(m *databaseMetrics) float64 { return m.downloadDuration },
+		func(m *databaseMetrics, v float64) { m.downloadDuration = v }},
+	{"echodb_backup_size_bytes", "gauge", "Size of the last successful dump.",
+		func(m *databaseMetrics) float64 { return m.size },
+		func(m *databaseMetrics, v float64) { m.size = v }},
+	{"echodb_backup_transfer_bytes_per_second", "gauge", "Download throughput of the last successful backup.",
+		func(m *databaseMetrics) float64 { return m.throughput },
+		func(m *databaseMetrics, v float64) { m.throughput = v }},
+	{"echodb_backup_last_success_timestamp_seconds", "gauge", "Unix time of the last successful backup.",
+		func(m *databaseMetrics) float64 { return m.lastSuccess },
+		func(m *databaseMetrics, v float64) { m.lastSuccess = v }},
+	{"echodb_backup_success_total", "counter", "Number of successful backups.",
+		func(m *databaseMetrics) float64 { return float64(m.successes) },
+		func(m *databaseMetrics, v float64) { m.successes = uint64(v) }},
+	{"echodb_backup_failures_total", "counter", "Number of failed backups.",
+		func(m *databaseMetrics) float64 { return float64(m.failures) },
+		func(m *databaseMetrics, v float64) { m.failures = uint64(v) }},
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]key, 0, len(r.databases))
+	for k := range r.databases {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].server != keys[j].server {
+			return keys[i].server < keys[j].server
+		}
+		return keys[i].database < keys[j].database
+	})
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, def := range definitions {
+		_, _ = fmt.Fprintf(cw, "# HELP %s %s\n", def.name, def.help)
+		_, _ = fmt.Fprintf(cw, "# TYPE %s %s\n", def.name, def.kind)
+		for _, k := range keys {
+			_, _ = fmt.Fprintf(cw, "%s{server=\"%s\",database=\"%s\"} %g\n",
+				def.name, escape(k.server), escape(k.database), def.value(r.databases[k]))
+		}
+	}
+
+	if err := cw.w.Flush(); err != nil {
+		return cw.n, err
+	}
+	return cw.n, cw.err
+}
+
+// WriteTextfile writes the metrics for the node_exporter textfile collector.
+// The file is replaced atomically so the collector never reads a partial file.
+func (r *Registry) WriteTextfile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create metrics file: %w", err)
+	}
+
+	if _, err := r.WriteTo(tmp); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	return nil
+}
+
+// ReadTextfile loads the series of a textfile written by an earlier run, so
+// databases that are not backed up in this run keep their metrics and the
+// counters keep growing. Databases already in the registry are left as they
+// are. A missing file is not an error.
+func (r *Registry) ReadTextfile(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read metrics file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	setters := make(map[string]func(*databaseMetrics, float64), len(definitions))
+	for _, def := range definitions {
+		setters[def.name] = def.set
+	}
+
+	loaded := make(map[key]*databaseMetrics)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, k, value, ok := parseSample(scanner.Text())
+		set := setters[name]
+		if !ok || set == nil {
+			continue
+		}
+		m, ok := loaded[k]
+		if !ok {
+			m = &databaseMetrics{}
+			loaded[k] = m
+		}
+		set(m, value)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read metrics file: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, m := range loaded {
+		if _, ok := r.databases[k]; !ok {
+			r.databases[k] = m
+		}
+	}
+	return nil
+}
+
+// parseSample parses a sample line written by WriteTo, e.g.
+// `echodb_backup_size_bytes{server="db1",database="app"} 2048`.
+func parseSample(line string) (string, key, float64, bool) {
+	name, rest, ok := strings.Cut(line, "{")
+	if !ok || strings.HasPrefix(name, "#") {
+		return "", key{}, 0, false
+	}
+
+	var k key
+	for {
+		label, after, ok := strings.Cut(rest, "=\"")
+		if !ok {
+			return "", key{}, 0, false
+		}
+		value, after, ok := unquote(after)
+		if !ok {
+			return "", key{}, 0, false
+		}
+		switch label {
+		case "server":
+			k.server = value
+		case "database":
+			k.database = value
+		}
+		rest = strings.TrimPrefix(after, ",")
+		if strings.HasPrefix(rest, "}") {
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(rest[1:]), 64)
+	if err != nil {
+		return "", key{}, 0, false
+	}
+	return name, k, value, true
+}
+
+// unquote reads a label value up to its closing quote, reverting escape,
+// and returns the value and the text after the quote.
+func unquote(s string) (string, string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return b.String(), s[i+1:], true
+		case '\\':
+			i++
+			if i == len(s) {
+				return "", "", false
+			}
+			if s[i] == 'n' {
+				b.WriteByte('\n')
+			} else {
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", "", false
+}
+
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = r.WriteTo(w)
+	})
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
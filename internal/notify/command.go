@@ -0,0 +1,41 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"echodb/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Command runs a local command with the JSON message on stdin and the status
+// and body in the ECHODB_STATUS and ECHODB_MESSAGE environment variables.
+type Command struct {
+	Command string
+	Timeout time.Duration
+}
+
+func (c *Command) Send(ctx context.Context, msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	cmd := utils.ShellCommand(ctx, c.Command)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Env = append(os.Environ(),
+		"ECHODB_STATUS="+msg.Status,
+		"ECHODB_MESSAGE="+msg.Body,
+	)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("notify command failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
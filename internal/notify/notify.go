@@ -0,0 +1,154 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"echodb/internal/config"
+	"echodb/internal/report"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	StatusSuccess = "success"
+	StatusPartial = "partial"
+	StatusFailed  = "failed"
+)
+
+const defaultTemplate = `echodb backup {{.Status}}: {{.Succeeded}} of {{.Total}} succeeded
+{{range .Databases}}- {{.Server}}/{{.Database}}: {{.Status}}, {{size .Size}}, {{duration .Duration}}{{if .Error}}, {{.Error}}{{end}}
+{{end}}`
+
+// Message is the payload handed to every channel.
+type Message struct {
+	Status    string          `json:"status"`
+	Subject   string          `json:"subject"`
+	Body      string          `json:"text"`
+	Started   time.Time       `json:"started"`
+	Finished  time.Time       `json:"finished"`
+	Total     int             `json:"total"`
+	Succeeded int             `json:"succeeded"`
+	Databases []report.Result `json:"databases"`
+}
+
+type Channel interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type Notifier struct {
+	onFailureOnly bool
+	perDatabase   bool
+	tmpl          *template.Template
+	channels      []Channel
+}
+
+func New(cfg config.Notify) (*Notifier, error) {
+	text := cfg.Template
+	if text == "" {
+		text = defaultTemplate
+	}
+
+	tmpl, err := config.ParseNotifyTemplate(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notify template: %w", err)
+	}
+
+	n := &Notifier{
+		onFailureOnly: cfg.OnFailureOnly != nil && *cfg.OnFailureOnly,
+		perDatabase:   cfg.PerDatabase != nil && *cfg.PerDatabase,
+		tmpl:          tmpl,
+	}
+
+	for _, ch := range cfg.Channels {
+		switch ch.Type {
+		case "webhook":
+			n.channels = append(n.channels, &Webhook{URL: ch.URL, Timeout: ch.Timeout})
+		case "slack":
+			n.channels = append(n.channels, &Webhook{URL: ch.URL, Timeout: ch.Timeout, Slack: true})
+		case "email":
+			n.channels = append(n.channels, &Email{
+				Host:     ch.Host,
+				Port:     ch.Port,
+				Username: ch.Username,
+				Password: ch.Password,
+				From:     ch.From,
+				To:       ch.To,
+				Timeout:  ch.Timeout,
+			})
+		case "command":
+			n.channels = append(n.channels, &Command{Command: ch.Command, Timeout: ch.Timeout})
+		default:
+			return nil, fmt.Errorf("unsupported notify channel: %s", ch.Type)
+		}
+	}
+
+	return n, nil
+}
+
+func (n *Notifier) Enabled() bool {
+	return n != nil && len(n.channels) > 0
+}
+
+func (n *Notifier) PerDatabase() bool {
+	return n.Enabled() && n.perDatabase
+}
+
+// NotifyRun sends the summary of a finished run.
+func (n *Notifier) NotifyRun(ctx context.Context, rep *report.Report) error {
+	return n.send(ctx, rep.Started(), rep.Finished(), rep.Results())
+}
+
+// NotifyDatabase sends the result of a single database.
+func (n *Notifier) NotifyDatabase(ctx context.Context, result report.Result) error {
+	finished := time.Now()
+	return n.send(ctx, finished.Add(-result.Duration), finished, []report.Result{result})
+}
+
+func (n *Notifier) send(ctx context.Context, started, finished time.Time, results []report.Result) error {
+	if !n.Enabled() {
+		return nil
+	}
+
+	msg := Message{
+		Started:   started,
+		Finished:  finished,
+		Total:     len(results),
+		Databases: results,
+	}
+	for _, result := range results {
+		if result.Status == report.StatusSuccess {
+			msg.Succeeded++
+		}
+	}
+
+	switch msg.Succeeded {
+	case msg.Total:
+		msg.Status = StatusSuccess
+	case 0:
+		msg.Status = StatusFailed
+	default:
+		msg.Status = StatusPartial
+	}
+
+	if n.onFailureOnly && msg.Status == StatusSuccess {
+		return nil
+	}
+
+	var body bytes.Buffer
+	if err := n.tmpl.Execute(&body, msg); err != nil {
+		return fmt.Errorf("failed to render notify template: %w", err)
+	}
+	msg.Body = body.String()
+	msg.Subject = strings.SplitN(strings.TrimSpace(msg.Body), "\n", 2)[0]
+
+	var errs []error
+	for _, ch := range n.channels {
+		if err := ch.Send(ctx, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,249 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"echodb/internal/config"
+	"echodb/internal/report"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testResults() []report.Result {
+	return []report.Result{
+		{Database: "app", Server: "srv", Status: report.StatusSuccess, Size: 2048, Duration: time.Second},
+		{Database: "crm", Server: "srv", Status: report.StatusFailed, Error: "access denied"},
+	}
+}
+
+func TestWebhookPostsMessage(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer server.Close()
+
+	n, err := New(config.Notify{Channels: []config.NotifyChannel{
+		{Type: "webhook", URL: server.URL, Timeout: time.Second},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finished := time.Now()
+	if err := n.send(context.Background(), finished.Add(-time.Minute), finished, testResults()); err != nil {
+		t.Fatal(err)
+	}
+
+	var msg Message
+	if err := json.Unmarshal(<-bodies, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusPartial || msg.Total != 2 || msg.Succeeded != 1 {
+		t.Errorf("got status %s, %d of %d succeeded", msg.Status, msg.Succeeded, msg.Total)
+	}
+	if len(msg.Databases) != 2 || msg.Databases[1].Error != "access denied" {
+		t.Errorf("databases not in payload: %+v", msg.Databases)
+	}
+	if !strings.Contains(msg.Body, "srv/crm: failed") {
+		t.Errorf("body does not list the failed database:\n%s", msg.Body)
+	}
+}
+
+func TestSlackPostsTextOnly(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer server.Close()
+
+	w := &Webhook{URL: server.URL, Timeout: time.Second, Slack: true}
+	if err := w.Send(context.Background(), Message{Body: "echodb backup failed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var payload map[string]any
+	if err := json.Unmarshal(<-bodies, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(payload) != 1 || payload["text"] != "echodb backup failed" {
+		t.Errorf("unexpected slack payload %v", payload)
+	}
+}
+
+func TestWebhookReportsErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid token", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	w := &Webhook{URL: server.URL, Timeout: time.Second}
+	err := w.Send(context.Background(), Message{})
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "invalid token") {
+		t.Errorf("got error %v, want the status and body of the response", err)
+	}
+}
+
+func TestOnFailureOnlySkipsSuccess(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	onFailureOnly := true
+	n, err := New(config.Notify{
+		OnFailureOnly: &onFailureOnly,
+		Channels:      []config.NotifyChannel{{Type: "webhook", URL: server.URL, Timeout: time.Second}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finished := time.Now()
+	if err := n.send(context.Background(), finished, finished, testResults()[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if got := requests.Load(); got != 0 {
+		t.Errorf("successful run sent %d notifications", got)
+	}
+
+	if err := n.send(context.Background(), finished, finished, testResults()[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("failed run sent %d notifications, want 1", got)
+	}
+}
+
+// fakeSMTP accepts one SMTP session and returns the envelope and the data of
+// the message. It offers neither STARTTLS nor AUTH.
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+
+		var session strings.Builder
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+			switch verb {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "MAIL", "RCPT":
+				session.WriteString(line + "\n")
+				reply("250 OK")
+			case "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if data == ".\r\n" {
+						break
+					}
+					session.WriteString(data)
+				}
+				reply("250 OK")
+			case "QUIT":
+				reply("221 Bye")
+				received <- session.String()
+				return
+			default:
+				reply("502 Not implemented")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), received
+}
+
+func TestEmailSendsMessage(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	host, port, _ := net.SplitHostPort(addr)
+
+	e := &Email{
+		Host:    host,
+		Port:    port,
+		From:    "echodb@example.com",
+		To:      []string{"ops@example.com", "dba@example.com"},
+		Timeout: 5 * time.Second,
+	}
+	msg := Message{Subject: "echodb backup failed: 0 of 1 succeeded", Body: "echodb backup failed\n- srv/app: failed\n"}
+	if err := e.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	var session string
+	select {
+	case session = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	for _, want := range []string{
+		"MAIL FROM:<echodb@example.com>",
+		"RCPT TO:<ops@example.com>",
+		"RCPT TO:<dba@example.com>",
+		"Subject: echodb backup failed: 0 of 1 succeeded\r\n",
+		"To: ops@example.com, dba@example.com\r\n",
+		"- srv/app: failed\r\n",
+	} {
+		if !strings.Contains(session, want) {
+			t.Errorf("session does not contain %q:\n%s", want, session)
+		}
+	}
+}
+
+func TestEmailReportsUnreachableServer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	host, port, _ := net.SplitHostPort(addr)
+	e := &Email{Host: host, Port: port, From: "a@example.com", To: []string{"b@example.com"}, Timeout: time.Second}
+
+	err = e.Send(context.Background(), Message{})
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		t.Errorf("got error %v, want a connection error", err)
+	}
+}
@@ -0,0 +1,227 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusSuccess   Status = "success"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+type Result struct {
+	Database string        `json:"database"`
+	Server   string        `json:"server"`
+	Status   Status        `json:"status"`
+	File     string        `json:"file,omitempty"`
+	Size     int64         `json:"size"`
+	Duration time.Duration `json:"duration_ns"`
+	Retries  int           `json:"retries"`
+	Error    string        `json:"error,omitempty"`
+	// Stage timings measured by the backup.
+	DumpDuration     time.Duration `json:"dump_duration_ns"`
+	DownloadDuration time.Duration `json:"download_duration_ns"`
+	err              error
+}
+
+// Report collects the results of one run. It is safe for concurrent use.
+type Report struct {
+	mu       sync.Mutex
+	started  time.Time
+	finished time.Time
+	results  []Result
+}
+
+func New() *Report {
+	return &Report{started: time.Now()}
+}
+
+// Add records the result of a database and returns it with its status set.
+func (r *Report) Add(result Result, err error) Result {
+	if err != nil {
+		result.err = err
+		result.Error = err.Error()
+		if result.Status == "" {
+			result.Status = StatusFailed
+		}
+	} else if result.Status == "" {
+		result.Status = StatusSuccess
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, result)
+	return result
+}
+
+// Finish stops the run clock and sorts the results by server and database.
+func (r *Report) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.finished = time.Now()
+	sort.SliceStable(r.results, func(i, j int) bool {
+		if r.results[i].Server != r.results[j].Server {
+			return r.results[i].Server < r.results[j].Server
+		}
+		return r.results[i].Database < r.results[j].Database
+	})
+}
+
+func (r *Report) Started() time.Time {
+	return r.started
+}
+
+func (r *Report) Finished() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.finished
+}
+
+func (r *Report) Results() []Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Result{}, r.results...)
+}
+
+// Failed returns the number of results that did not succeed.
+func (r *Report) Failed() int {
+	failed := 0
+	for _, result := range r.Results() {
+		if result.Status != StatusSuccess {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Err joins the errors of every database that did not succeed.
+func (r *Report) Err() error {
+	var errs []error
+	for _, result := range r.Results() {
+		if result.err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s: %w", result.Server, result.Database, result.err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Report) PrintTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "DATABASE\tSERVER\tSTATUS\tSIZE\tDURATION\tRETRIES\tERROR")
+	for _, result := range r.Results() {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			result.Database,
+			result.Server,
+			result.Status,
+			FormatSize(result.Size),
+			result.Duration.Round(time.Millisecond),
+			result.Retries,
+			result.Error,
+		)
+	}
+	return tw.Flush()
+}
+
+func (r *Report) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(struct {
+		Started  time.Time `json:"started"`
+		Finished time.Time `json:"finished"`
+		Results  []Result  `json:"results"`
+	}{r.started, r.finished, r.Results()}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write report %s: %w", path, err)
+	}
+	return nil
+}
+
+type junitSuite struct {
+	XMLName   xml.Name    `xml:"testsuite"`
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Time      float64     `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the report as a JUnit XML test suite with one test case
+// per database.
+func (r *Report) WriteJUnit(path string) error {
+	suite := junitSuite{
+		Name:      "echodb",
+		Time:      r.finished.Sub(r.started).Seconds(),
+		Timestamp: r.started.Format(time.RFC3339),
+	}
+
+	for _, result := range r.Results() {
+		c := junitCase{
+			Name:      result.Database,
+			ClassName: result.Server,
+			Time:      result.Duration.Seconds(),
+		}
+		switch result.Status {
+		case StatusFailed:
+			c.Failure = &junitMessage{Message: result.Error}
+			suite.Failures++
+		case StatusCancelled:
+			c.Skipped = &junitMessage{Message: result.Error}
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, c)
+		suite.Tests++
+	}
+
+	data, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, append([]byte(xml.Header), data...), 0o644); err != nil {
+		return fmt.Errorf("failed to write report %s: %w", path, err)
+	}
+	return nil
+}
+
+// FormatSize formats a byte count with a binary unit.
+func FormatSize(size int64) string {
+	switch {
+	case size >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(size)/(1<<30))
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
@@ -0,0 +1,97 @@
+package restore
+
+import (
+	"context"
+	"echodb/internal/command"
+	"echodb/internal/connect"
+	"echodb/internal/retry"
+	"echodb/pkg/logging"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type Restore struct {
+	ctx        context.Context
+	conn       *connect.Connect
+	restoreCmd string
+	restoreEnv map[string]string
+	file       string
+}
+
+func NewApp(ctx context.Context, conn *connect.Connect, cmd command.Command, file string) *Restore {
+	return &Restore{
+		ctx:        ctx,
+		conn:       conn,
+		restoreCmd: cmd.Cmd,
+		restoreEnv: cmd.Env,
+		file:       file,
+	}
+}
+
+// Restore uploads the local dump file to the stdin of the restore command
+// on the server.
+func (r *Restore) Restore() error {
+	file, err := os.Open(r.file)
+	if err != nil {
+		return fmt.Errorf("failed to open dump: %w", err)
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	logging.L(r.ctx).Info("Restoring dump", logging.StringAttr("file", r.file))
+	fmt.Println("Restoring dump: ", r.file)
+
+	restoreTimeNow := time.Now()
+	progress := &progressReader{r: file, total: info.Size()}
+	output, err := r.conn.StreamCommandEnv(r.restoreCmd, r.restoreEnv, progress, nil)
+	fmt.Println()
+	if err != nil {
+		logging.L(r.ctx).Error("Failed to restore dump", logging.ErrAttr(err))
+		if output = strings.TrimSpace(output); output != "" {
+			err = fmt.Errorf("failed to restore dump: %w: %s", err, output)
+		} else {
+			err = fmt.Errorf("failed to restore dump: %w", err)
+		}
+		// The restore command ran and failed, running it again would apply
+		// the dump once more. Only a lost upload is retried.
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			return retry.Permanent(err)
+		}
+		return err
+	}
+
+	restoreTimeSec := fmt.Sprintf("%.2f sec", time.Since(restoreTimeNow).Seconds())
+	logging.L(r.ctx).Info("The dump was successfully restored", logging.StringAttr("time", restoreTimeSec))
+	fmt.Println("Restore complete:", r.file)
+
+	return nil
+}
+
+// progressReader prints how much of the dump was uploaded.
+type progressReader struct {
+	r     io.Reader
+	done  int64
+	total int64
+}
+
+func (p *progressReader) Read(data []byte) (int, error) {
+	n, err := p.r.Read(data)
+	p.done += int64(n)
+	if p.total > 0 {
+		fmt.Printf("\rUploading... %.1f%% (%d/%d bytes)", float64(p.done)/float64(p.total)*100, p.done, p.total)
+	}
+	return n, err
+}
@@ -0,0 +1,140 @@
+package retry
+
+import (
+	"context"
+	"echodb/pkg/logging"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+const (
+	StageConnect  = "connect"
+	StageDump     = "dump"
+	StageDownload = "download"
+	// StageUpload is the upload of a dump to the restore command.
+	StageUpload = "upload"
+)
+
+type Policy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	Stages      []string
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as not worth retrying, e.g. an authentication failure.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// Retrier runs the stages of one backup under a policy and counts the
+// retries it made.
+type Retrier struct {
+	ctx     context.Context
+	policy  Policy
+	mu      sync.Mutex
+	retries int
+}
+
+func New(ctx context.Context, policy Policy) *Retrier {
+	return &Retrier{ctx: ctx, policy: policy}
+}
+
+// Do runs fn until it succeeds, returns a permanent error, the context is
+// cancelled or the attempts of a retryable stage are used up.
+func (r *Retrier) Do(stage string, fn func() error) error {
+	attempts := 1
+	if r.retryable(stage) {
+		attempts = r.policy.MaxAttempts
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+
+		if IsPermanent(err) || attempt >= attempts || r.ctx.Err() != nil {
+			return err
+		}
+
+		delay := r.delay(attempt)
+		logging.L(r.ctx).Warn(
+			"Attempt failed, retrying",
+			logging.StringAttr("stage", stage),
+			logging.IntAttr("attempt", attempt),
+			logging.IntAttr("max_attempts", attempts),
+			logging.DurationAttr("delay", delay),
+			logging.ErrAttr(err),
+		)
+		fmt.Printf("%s failed (attempt %d/%d), retrying in %s: %v\n", stage, attempt, attempts, delay.Round(time.Millisecond), err)
+
+		r.mu.Lock()
+		r.retries++
+		r.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-r.ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *Retrier) Retries() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.retries
+}
+
+func (r *Retrier) retryable(stage string) bool {
+	for _, s := range r.policy.Stages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// delay doubles the backoff with every attempt up to MaxBackoff and picks a
+// random duration in its upper half.
+func (r *Retrier) delay(attempt int) time.Duration {
+	d := r.policy.Backoff
+	for i := 1; i < attempt && d < r.policy.MaxBackoff; i++ {
+		d *= 2
+	}
+	if r.policy.MaxBackoff > 0 && d > r.policy.MaxBackoff {
+		d = r.policy.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
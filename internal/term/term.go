@@ -3,12 +3,14 @@ package term
 import (
 	"errors"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/manifoldco/promptui"
 )
 
+// ErrInterrupted is returned by Run when the user cancels the prompt.
+var ErrInterrupted = errors.New("selection interrupted")
+
 type pepper struct {
 	Name   string
 	Number int
@@ -33,7 +35,7 @@ func (d *Data) SetList(list []string) {
 	d.List = items
 }
 
-func (d *Data) Run() {
+func (d *Data) Run() error {
 	items := d.List
 
 	templates := &promptui.SelectTemplates{
@@ -63,14 +65,14 @@ func (d *Data) Run() {
 	i, _, err := prompt.Run()
 
 	if err != nil {
-		if errors.Is(err, promptui.ErrInterrupt) {
-			os.Exit(0)
+		if errors.Is(err, promptui.ErrInterrupt) || errors.Is(err, promptui.ErrEOF) {
+			return ErrInterrupted
 		}
-		fmt.Printf("Prompt failed %v\n", err)
-		return
+		return fmt.Errorf("prompt failed: %w", err)
 	}
 
 	d.Selected = items[i].Name
+	return nil
 }
 
 func (d *Data) SetTitle(title string) {
@@ -4,50 +4,33 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 )
 
-func ArchivedLocalFile(dbName, file, sourceDir, targetDir string) error {
-
-	actualFile := fmt.Sprintf("%s/%s", sourceDir, filepath.Base(file))
-
+// ArchivedLocalFile moves the files of sourceDir whose names match pattern,
+// except file itself, into targetDir.
+func ArchivedLocalFile(pattern *regexp.Regexp, file, sourceDir, targetDir string) error {
 	if err := createDir(targetDir); err != nil {
 		return fmt.Errorf("failed to create directory %s: %w", targetDir, err)
 	}
 
-	absActualFile, err := filepath.Abs(actualFile)
+	entries, err := os.ReadDir(sourceDir)
 	if err != nil {
-		return fmt.Errorf("error getting an absolute path for %s: %v", actualFile, err)
-	}
-
-	patterns := []string{
-		fmt.Sprintf("%s/%s*.sql", sourceDir, dbName),
-		fmt.Sprintf("%s/%s*", sourceDir, dbName),
+		return fmt.Errorf("couldn't read the directory %s: %v", sourceDir, err)
 	}
 
-	for _, pattern := range patterns {
-		matches, err := filepath.Glob(pattern)
-		if err != nil {
-			return fmt.Errorf("error when searching for files using a template %s: %v", pattern, err)
+	actual := filepath.Base(file)
+	for _, entry := range entries {
+		base := entry.Name()
+		if !entry.Type().IsRegular() || base == actual || !pattern.MatchString(base) {
+			continue
 		}
 
-		for _, file := range matches {
-			absPath, err := filepath.Abs(file)
-			if err != nil {
-				return fmt.Errorf("error getting an absolute path for %s: %v", file, err)
-			}
-
-			if absPath == absActualFile {
-				continue
-			}
-
-			base := filepath.Base(file)
-			dest := filepath.Join(targetDir, base)
-			err = os.Rename(file, dest)
-			if err != nil {
-				return fmt.Errorf("couldn't move the file %s -> %s: %v", file, dest, err)
-			}
-			fmt.Printf("The %s file moved to %s\n", base, targetDir)
+		dest := filepath.Join(targetDir, base)
+		if err := os.Rename(filepath.Join(sourceDir, base), dest); err != nil {
+			return fmt.Errorf("couldn't move the file %s -> %s: %v", base, dest, err)
 		}
+		fmt.Printf("The %s file moved to %s\n", base, targetDir)
 	}
 
 	return nil
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package utils
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// DiskFree returns the number of bytes available to the current user on the
+// filesystem holding path.
+func DiskFree(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, fmt.Errorf("failed to stat filesystem %s: %w", path, err)
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// DiskFree returns the number of bytes available to the current user on the
+// volume holding path.
+func DiskFree(path string) (uint64, error) {
+	dir, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var free, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(dir, &free, &total, &totalFree); err != nil {
+		return 0, fmt.Errorf("failed to stat volume %s: %w", path, err)
+	}
+	return free, nil
+}
@@ -0,0 +1,15 @@
+package utils
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+)
+
+// ShellCommand runs command through the local shell.
+func ShellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -15,6 +16,23 @@ type TemplateData struct {
 	Template  string
 }
 
+// Layouts of the time placeholders. In Go layouts `2` is the day and `5`
+// the second without padding, so 2 Oct 2026 10:00:07 renders as 2027.10.02
+// and 27-00-07. In {%datetime%} `_2` is the day padded with a space, which
+// becomes `_` before days 1 to 9 and is dropped otherwise.
+const (
+	dateLayout = "2025.01.02"
+	timeLayout = "25-04-05"
+)
+
+// placeholderPatterns match exactly what each time placeholder renders.
+var placeholderPatterns = map[string]string{
+	"{%date%}":     `\d{4,6}\.\d{2}\.\d{2}`,
+	"{%time%}":     `\d{2,4}-\d{2}-\d{2}`,
+	"{%datetime%}": `\d{4,6}\.\d{2}\.\d{2}(?:_\d|\d{2})\d{1,2}-\d{2}-\d{2}`,
+	"{%ts%}":       `\d+`,
+}
+
 func GetTemplateFileName(data TemplateData) string {
 
 	if data.Template == "" {
@@ -32,9 +50,9 @@ func GetTemplateFileName(data TemplateData) string {
 	replacements := map[string]string{
 		"{%srv%}":      data.Server,
 		"{%db%}":       data.Database,
-		"{%date%}":     data.Time.Format("2025.01.02"),
-		"{%time%}":     data.Time.Format("25-04-05"),
-		"{%datetime%}": data.Time.Format("2025.01.02_25-04-05"),
+		"{%date%}":     data.Time.Format(dateLayout),
+		"{%time%}":     data.Time.Format(timeLayout),
+		"{%datetime%}": data.Time.Format(dateLayout + "_" + timeLayout),
 		"{%ts%}":       strconv.FormatInt(data.Time.Unix(), 10),
 	}
 
@@ -45,3 +63,43 @@ func GetTemplateFileName(data TemplateData) string {
 
 	return strings.ReplaceAll(result, " ", "_")
 }
+
+var placeholderPattern = regexp.MustCompile(`\{%[a-z]+%\}`)
+
+// TemplatePattern returns a pattern matching the file names the template
+// renders for the server and database at any time and with any extension.
+// Time placeholders match exactly their rendered format, so the dumps of
+// `app` match neither those of `app_2` nor those of `app_audit`.
+func TemplatePattern(data TemplateData) *regexp.Regexp {
+	if data.Template == "" {
+		data.Template = "{%srv%}_{%db%}_{%date%}"
+	}
+
+	literal := func(s string) string {
+		return regexp.QuoteMeta(strings.ReplaceAll(s, " ", "_"))
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, loc := range placeholderPattern.FindAllStringIndex(data.Template, -1) {
+		b.WriteString(literal(data.Template[last:loc[0]]))
+		switch placeholder := data.Template[loc[0]:loc[1]]; placeholder {
+		case "{%srv%}":
+			b.WriteString(literal(data.Server))
+		case "{%db%}":
+			b.WriteString(literal(data.Database))
+		default:
+			if pattern, ok := placeholderPatterns[placeholder]; ok {
+				b.WriteString(pattern)
+			} else {
+				b.WriteString(literal(placeholder))
+			}
+		}
+		last = loc[1]
+	}
+	b.WriteString(literal(data.Template[last:]))
+	b.WriteString(`\..+$`)
+
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTemplatePattern(t *testing.T) {
+	times := []time.Time{
+		time.Date(2026, 10, 2, 10, 0, 7, 0, time.UTC),
+		time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC),
+		time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC),
+	}
+	templates := []string{
+		"",
+		"{%srv%}_{%db%}_{%time%}",
+		"{%srv%}_{%db%}_{%datetime%}",
+		"{%db%}_{%ts%}",
+		"{%db%} {%date%}",
+	}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		database string
+		want     bool
+	}{
+		{"same database", "app", "app", true},
+		{"database with a numeric suffix", "app", "app_2", false},
+		{"database with a word suffix", "app", "app_audit", false},
+		{"database that is a prefix", "app_2", "app", false},
+	}
+
+	for _, tmpl := range templates {
+		for _, tt := range tests {
+			pattern := TemplatePattern(TemplateData{Server: "srv", Database: tt.pattern, Template: tmpl})
+			for _, tm := range times {
+				name := GetTemplateFileName(TemplateData{
+					Time:     tm,
+					Server:   "srv",
+					Database: tt.database,
+					Template: tmpl,
+				}) + ".sql.gz"
+				if got := pattern.MatchString(name); got != tt.want {
+					t.Errorf("%s, template %q: pattern %s matches %s: %v, want %v",
+						tt.name, tmpl, pattern, name, got, tt.want)
+				}
+			}
+		}
+	}
+}